@@ -0,0 +1,263 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent manages the CloudBackup agent installed in this host
+package agent
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Default locations of the agent installed by the server install script
+const (
+	DefaultService    = "cloudbackup-agent"
+	DefaultConfigDir  = "/etc/cloudbackup-agent"
+	DefaultConfigFile = DefaultConfigDir + "/agent.conf"
+	DefaultBinary     = "/usr/local/bin/cloudbackup-agent"
+	DefaultLogFile    = "/var/log/cloudbackup-agent.log"
+)
+
+// Backup archives the given files and directories into a tar.gz file, so they
+// can be restored with Restore. Missing paths are skipped
+func Backup(archive string, paths []string) (err error) {
+	f, err := os.OpenFile(archive, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			os.Remove(archive)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, root := range paths {
+		if _, err := os.Lstat(root); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			return addToArchive(tw, path, info)
+		})
+
+		if err != nil {
+			return fmt.Errorf("%s, while backing up %s", err, root)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func addToArchive(tw *tar.Writer, path string, info os.FileInfo) error {
+	link := ""
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+
+	if err != nil {
+		return err
+	}
+
+	header.Name = path
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+
+	return err
+}
+
+// Restore replaces the given paths with their contents in a Backup archive.
+// Paths missing from the archive are removed, as they did not exist before.
+// The whole archive is checked before anything is removed, so a corrupted
+// or foreign archive leaves the paths untouched
+func Restore(archive string, paths []string) error {
+	err := readArchive(archive, func(tr *tar.Reader, header *tar.Header) error {
+		if !inPaths(header.Name, paths) {
+			return fmt.Errorf("Refusing to restore %s, it is not one of the backed up paths", header.Name)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range paths {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+
+	return readArchive(archive, func(tr *tar.Reader, header *tar.Header) error {
+		if err := extract(tr, header); err != nil {
+			return fmt.Errorf("%s, while restoring %s", err, header.Name)
+		}
+
+		return nil
+	})
+}
+
+// readArchive calls fn with every entry of a gzipped tar archive
+func readArchive(archive string, fn func(*tar.Reader, *tar.Header) error) error {
+	f, err := os.Open(archive)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(tr, header); err != nil {
+			return err
+		}
+	}
+}
+
+func extract(tr *tar.Reader, header *tar.Header) error {
+	mode := os.FileMode(header.Mode).Perm()
+
+	if err := os.MkdirAll(filepath.Dir(header.Name), 0755); err != nil {
+		return err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(header.Name, mode); err != nil {
+			return err
+		}
+
+		if err := os.Chmod(header.Name, mode); err != nil {
+			return err
+		}
+
+		return os.Chown(header.Name, header.Uid, header.Gid)
+
+	case tar.TypeSymlink:
+		if err := os.Symlink(header.Linkname, header.Name); err != nil {
+			return err
+		}
+
+		return os.Lchown(header.Name, header.Uid, header.Gid)
+
+	case tar.TypeReg:
+		f, err := os.OpenFile(header.Name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		return os.Chown(header.Name, header.Uid, header.Gid)
+	}
+
+	return nil
+}
+
+// inPaths reports if name is one of paths or inside one of them
+func inPaths(name string, paths []string) bool {
+	name = filepath.Clean(name)
+
+	for _, p := range paths {
+		p = filepath.Clean(p)
+
+		if name == p || strings.HasPrefix(name, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Restart restarts the agent systemd service
+func Restart(service string) error {
+	out, err := exec.Command("systemctl", "restart", service).CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("%s, while restarting %s: %s", err, service, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
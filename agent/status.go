@@ -0,0 +1,192 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit is the state of the agent systemd unit
+type Unit struct {
+	Name        string    `json:"name"`
+	ActiveState string    `json:"activeState"`
+	SubState    string    `json:"subState"`
+	MainPID     int       `json:"mainPid"`
+	Since       time.Time `json:"since"`
+}
+
+// Running reports if the unit is active and its process running
+func (u Unit) Running() bool {
+	return u.ActiveState == "active" && u.SubState == "running"
+}
+
+func (u Unit) String() string {
+	s := fmt.Sprintf("%s %s (%s)", u.Name, u.ActiveState, u.SubState)
+
+	if !u.Since.IsZero() {
+		s += " since " + u.Since.Format(time.RFC3339)
+	}
+
+	if u.MainPID > 0 {
+		s += fmt.Sprintf(", pid %d", u.MainPID)
+	}
+
+	return s
+}
+
+// UnitStatus asks systemd for the state of the agent service
+func UnitStatus(service string) (Unit, error) {
+	out, err := exec.Command("systemctl", "show", service, "--no-pager",
+		"--property=ActiveState,SubState,MainPID,ActiveEnterTimestamp").Output()
+
+	if err != nil {
+		return Unit{Name: service}, fmt.Errorf("%s, while asking systemd for %s", err, service)
+	}
+
+	unit := Unit{Name: service}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "ActiveState":
+			unit.ActiveState = parts[1]
+		case "SubState":
+			unit.SubState = parts[1]
+		case "MainPID":
+			unit.MainPID, _ = strconv.Atoi(parts[1])
+		case "ActiveEnterTimestamp":
+			unit.Since, _ = time.Parse("Mon 2006-01-02 15:04:05 MST", parts[1])
+		}
+	}
+
+	return unit, nil
+}
+
+var versionRegexp = regexp.MustCompile(`\d+\.\d+(\.\d+)?([-+.\w]*)?`)
+
+// Version runs the agent binary to get its version
+func Version(binary string) (string, error) {
+	out, err := exec.Command(binary, "--version").CombinedOutput()
+
+	if err != nil {
+		return "", fmt.Errorf("%s, while running %s --version", err, binary)
+	}
+
+	if v := versionRegexp.FindString(string(out)); v != "" {
+		return v, nil
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Config describes the agent config file, without its contents as it holds
+// the database credentials
+type Config struct {
+	Path     string      `json:"path"`
+	Size     int64       `json:"size"`
+	Mode     os.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"modTime"`
+	ServerID int         `json:"serverId,omitempty"`
+}
+
+// WorldReadable reports if any user in the host can read the config file
+func (c Config) WorldReadable() bool {
+	return c.Mode.Perm()&0004 != 0
+}
+
+// ReadConfig describes the agent config file, looking for the server ID in
+// its 'key = value' or 'key: value' lines
+func ReadConfig(path string) (Config, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return Config{Path: path}, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+
+	if err != nil {
+		return Config{Path: path}, err
+	}
+
+	config := Config{Path: path, Size: info.Size(), Mode: info.Mode(), ModTime: info.ModTime()}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		sep := strings.IndexAny(line, "=:")
+
+		if sep < 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key := strings.ToLower(strings.Trim(strings.TrimSpace(line[:sep]), `"'`))
+
+		if strings.NewReplacer("-", "", "_", "").Replace(key) == "serverid" {
+			config.ServerID, _ = strconv.Atoi(strings.Trim(strings.TrimSpace(line[sep+1:]), `"'`))
+		}
+	}
+
+	return config, scanner.Err()
+}
+
+// LogTail returns the last n lines of the agent log file, or of its journal if
+// the file does not exist
+func LogTail(service, logFile string, n int) ([]string, error) {
+	if _, err := os.Stat(logFile); err != nil {
+		out, err := exec.Command("journalctl", "-u", service, "-n", strconv.Itoa(n), "--no-pager", "-o", "short-iso").Output()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s, while reading the %s journal", err, service)
+		}
+
+		return lastLines(string(out), n), nil
+	}
+
+	out, err := exec.Command("tail", "-n", strconv.Itoa(n), logFile).Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s, while reading %s", err, logFile)
+	}
+
+	return lastLines(string(out), n), nil
+}
+
+func lastLines(s string, n int) []string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines
+}
@@ -0,0 +1,434 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package apimock
+
+import (
+	"sync"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+)
+
+// ClientAPI is a mock of api.ClientAPI. Each method calls the field of the same
+// name with the Func suffix, and panics if it is not set
+type ClientAPI struct {
+	mu    sync.Mutex
+	calls map[string]int
+
+	GetBackupKeysFunc      func() ([]byte, error)
+	ListBackupsFunc        func(filter api.BackupFilter) ([]api.Backup, error)
+	ListHoldsFunc          func(backupID int) ([]api.BackupHold, error)
+	CreateHoldFunc         func(hold api.BackupHold) (api.BackupHold, error)
+	DeleteHoldFunc         func(id int) error
+	GetServerFunc          func(id int) (api.Server, error)
+	ListServersFunc        func() ([]api.Server, error)
+	CreateServerFunc       func(name string, dbType api.DatabaseType, readonly bool, dbHost string, dbPort string, dbUser string, dbPass string) (api.Server, error)
+	UpdateServerFunc       func(server api.Server) error
+	DeleteServerFunc       func(id int) error
+	GetServerStatusFunc    func(id int) (api.ServerStatus, error)
+	GetServerInstallFunc   func(id int) (api.Script, error)
+	GetServerUninstallFunc func(id int) (api.Script, error)
+	GetServerUpgradeFunc   func(id int) (api.Script, error)
+	GetStorageFunc         func(id int) (api.Storage, error)
+	ListStoragesFunc       func() ([]api.Storage, error)
+	CreateStorageFunc      func(storage api.Storage) (api.Storage, error)
+	UpdateStorageFunc      func(storage api.Storage) error
+	DeleteStorageFunc      func(id int) error
+	GetScheduleFunc        func(id int) (api.Schedule, error)
+	ListSchedulesFunc      func() ([]api.Schedule, error)
+	CreateScheduleFunc     func(name string, scheduleType api.ScheduleType, hours string, days string, cron string, timezone string) (api.Schedule, error)
+	UpdateScheduleFunc     func(schedule api.Schedule) error
+	DeleteScheduleFunc     func(id int) error
+	ListBlackoutsFunc      func(filter api.BlackoutFilter) ([]api.Blackout, error)
+	CreateBlackoutFunc     func(blackout api.Blackout) (api.Blackout, error)
+	DeleteBlackoutFunc     func(id int) error
+	GetRetentionFunc       func(id int) (api.Retention, error)
+	CreateRetentionFunc    func(retention api.Retention) (api.Retention, error)
+	UpdateRetentionFunc    func(retention api.Retention) error
+	DeleteRetentionFunc    func(id int) error
+	GetJobFunc             func(id int) (api.BackupJob, error)
+	ListJobsFunc           func() ([]api.BackupJob, error)
+	CreateJobFunc          func(job api.BackupJob) (api.BackupJob, error)
+	UpdateJobFunc          func(job api.BackupJob) error
+	DeleteJobFunc          func(id int) error
+}
+
+var _ api.ClientAPI = (*ClientAPI)(nil)
+
+// Calls returns how many times method was called
+func (m *ClientAPI) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[method]
+}
+
+func (m *ClientAPI) record(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+
+	m.calls[method]++
+}
+
+func (m *ClientAPI) GetBackupKeys() ([]byte, error) {
+	m.record("GetBackupKeys")
+
+	if m.GetBackupKeysFunc == nil {
+		panic("apimock: ClientAPI.GetBackupKeys called but GetBackupKeysFunc is not set")
+	}
+
+	return m.GetBackupKeysFunc()
+}
+
+func (m *ClientAPI) ListBackups(filter api.BackupFilter) ([]api.Backup, error) {
+	m.record("ListBackups")
+
+	if m.ListBackupsFunc == nil {
+		panic("apimock: ClientAPI.ListBackups called but ListBackupsFunc is not set")
+	}
+
+	return m.ListBackupsFunc(filter)
+}
+
+func (m *ClientAPI) ListHolds(backupID int) ([]api.BackupHold, error) {
+	m.record("ListHolds")
+
+	if m.ListHoldsFunc == nil {
+		panic("apimock: ClientAPI.ListHolds called but ListHoldsFunc is not set")
+	}
+
+	return m.ListHoldsFunc(backupID)
+}
+
+func (m *ClientAPI) CreateHold(hold api.BackupHold) (api.BackupHold, error) {
+	m.record("CreateHold")
+
+	if m.CreateHoldFunc == nil {
+		panic("apimock: ClientAPI.CreateHold called but CreateHoldFunc is not set")
+	}
+
+	return m.CreateHoldFunc(hold)
+}
+
+func (m *ClientAPI) DeleteHold(id int) error {
+	m.record("DeleteHold")
+
+	if m.DeleteHoldFunc == nil {
+		panic("apimock: ClientAPI.DeleteHold called but DeleteHoldFunc is not set")
+	}
+
+	return m.DeleteHoldFunc(id)
+}
+
+func (m *ClientAPI) GetServer(id int) (api.Server, error) {
+	m.record("GetServer")
+
+	if m.GetServerFunc == nil {
+		panic("apimock: ClientAPI.GetServer called but GetServerFunc is not set")
+	}
+
+	return m.GetServerFunc(id)
+}
+
+func (m *ClientAPI) ListServers() ([]api.Server, error) {
+	m.record("ListServers")
+
+	if m.ListServersFunc == nil {
+		panic("apimock: ClientAPI.ListServers called but ListServersFunc is not set")
+	}
+
+	return m.ListServersFunc()
+}
+
+func (m *ClientAPI) CreateServer(name string, dbType api.DatabaseType, readonly bool, dbHost string, dbPort string, dbUser string, dbPass string) (api.Server, error) {
+	m.record("CreateServer")
+
+	if m.CreateServerFunc == nil {
+		panic("apimock: ClientAPI.CreateServer called but CreateServerFunc is not set")
+	}
+
+	return m.CreateServerFunc(name, dbType, readonly, dbHost, dbPort, dbUser, dbPass)
+}
+
+func (m *ClientAPI) UpdateServer(server api.Server) error {
+	m.record("UpdateServer")
+
+	if m.UpdateServerFunc == nil {
+		panic("apimock: ClientAPI.UpdateServer called but UpdateServerFunc is not set")
+	}
+
+	return m.UpdateServerFunc(server)
+}
+
+func (m *ClientAPI) DeleteServer(id int) error {
+	m.record("DeleteServer")
+
+	if m.DeleteServerFunc == nil {
+		panic("apimock: ClientAPI.DeleteServer called but DeleteServerFunc is not set")
+	}
+
+	return m.DeleteServerFunc(id)
+}
+
+func (m *ClientAPI) GetServerStatus(id int) (api.ServerStatus, error) {
+	m.record("GetServerStatus")
+
+	if m.GetServerStatusFunc == nil {
+		panic("apimock: ClientAPI.GetServerStatus called but GetServerStatusFunc is not set")
+	}
+
+	return m.GetServerStatusFunc(id)
+}
+
+func (m *ClientAPI) GetServerInstall(id int) (api.Script, error) {
+	m.record("GetServerInstall")
+
+	if m.GetServerInstallFunc == nil {
+		panic("apimock: ClientAPI.GetServerInstall called but GetServerInstallFunc is not set")
+	}
+
+	return m.GetServerInstallFunc(id)
+}
+
+func (m *ClientAPI) GetServerUninstall(id int) (api.Script, error) {
+	m.record("GetServerUninstall")
+
+	if m.GetServerUninstallFunc == nil {
+		panic("apimock: ClientAPI.GetServerUninstall called but GetServerUninstallFunc is not set")
+	}
+
+	return m.GetServerUninstallFunc(id)
+}
+
+func (m *ClientAPI) GetServerUpgrade(id int) (api.Script, error) {
+	m.record("GetServerUpgrade")
+
+	if m.GetServerUpgradeFunc == nil {
+		panic("apimock: ClientAPI.GetServerUpgrade called but GetServerUpgradeFunc is not set")
+	}
+
+	return m.GetServerUpgradeFunc(id)
+}
+
+func (m *ClientAPI) GetStorage(id int) (api.Storage, error) {
+	m.record("GetStorage")
+
+	if m.GetStorageFunc == nil {
+		panic("apimock: ClientAPI.GetStorage called but GetStorageFunc is not set")
+	}
+
+	return m.GetStorageFunc(id)
+}
+
+func (m *ClientAPI) ListStorages() ([]api.Storage, error) {
+	m.record("ListStorages")
+
+	if m.ListStoragesFunc == nil {
+		panic("apimock: ClientAPI.ListStorages called but ListStoragesFunc is not set")
+	}
+
+	return m.ListStoragesFunc()
+}
+
+func (m *ClientAPI) CreateStorage(storage api.Storage) (api.Storage, error) {
+	m.record("CreateStorage")
+
+	if m.CreateStorageFunc == nil {
+		panic("apimock: ClientAPI.CreateStorage called but CreateStorageFunc is not set")
+	}
+
+	return m.CreateStorageFunc(storage)
+}
+
+func (m *ClientAPI) UpdateStorage(storage api.Storage) error {
+	m.record("UpdateStorage")
+
+	if m.UpdateStorageFunc == nil {
+		panic("apimock: ClientAPI.UpdateStorage called but UpdateStorageFunc is not set")
+	}
+
+	return m.UpdateStorageFunc(storage)
+}
+
+func (m *ClientAPI) DeleteStorage(id int) error {
+	m.record("DeleteStorage")
+
+	if m.DeleteStorageFunc == nil {
+		panic("apimock: ClientAPI.DeleteStorage called but DeleteStorageFunc is not set")
+	}
+
+	return m.DeleteStorageFunc(id)
+}
+
+func (m *ClientAPI) GetSchedule(id int) (api.Schedule, error) {
+	m.record("GetSchedule")
+
+	if m.GetScheduleFunc == nil {
+		panic("apimock: ClientAPI.GetSchedule called but GetScheduleFunc is not set")
+	}
+
+	return m.GetScheduleFunc(id)
+}
+
+func (m *ClientAPI) ListSchedules() ([]api.Schedule, error) {
+	m.record("ListSchedules")
+
+	if m.ListSchedulesFunc == nil {
+		panic("apimock: ClientAPI.ListSchedules called but ListSchedulesFunc is not set")
+	}
+
+	return m.ListSchedulesFunc()
+}
+
+func (m *ClientAPI) CreateSchedule(name string, scheduleType api.ScheduleType, hours string, days string, cron string, timezone string) (api.Schedule, error) {
+	m.record("CreateSchedule")
+
+	if m.CreateScheduleFunc == nil {
+		panic("apimock: ClientAPI.CreateSchedule called but CreateScheduleFunc is not set")
+	}
+
+	return m.CreateScheduleFunc(name, scheduleType, hours, days, cron, timezone)
+}
+
+func (m *ClientAPI) UpdateSchedule(schedule api.Schedule) error {
+	m.record("UpdateSchedule")
+
+	if m.UpdateScheduleFunc == nil {
+		panic("apimock: ClientAPI.UpdateSchedule called but UpdateScheduleFunc is not set")
+	}
+
+	return m.UpdateScheduleFunc(schedule)
+}
+
+func (m *ClientAPI) DeleteSchedule(id int) error {
+	m.record("DeleteSchedule")
+
+	if m.DeleteScheduleFunc == nil {
+		panic("apimock: ClientAPI.DeleteSchedule called but DeleteScheduleFunc is not set")
+	}
+
+	return m.DeleteScheduleFunc(id)
+}
+
+func (m *ClientAPI) ListBlackouts(filter api.BlackoutFilter) ([]api.Blackout, error) {
+	m.record("ListBlackouts")
+
+	if m.ListBlackoutsFunc == nil {
+		panic("apimock: ClientAPI.ListBlackouts called but ListBlackoutsFunc is not set")
+	}
+
+	return m.ListBlackoutsFunc(filter)
+}
+
+func (m *ClientAPI) CreateBlackout(blackout api.Blackout) (api.Blackout, error) {
+	m.record("CreateBlackout")
+
+	if m.CreateBlackoutFunc == nil {
+		panic("apimock: ClientAPI.CreateBlackout called but CreateBlackoutFunc is not set")
+	}
+
+	return m.CreateBlackoutFunc(blackout)
+}
+
+func (m *ClientAPI) DeleteBlackout(id int) error {
+	m.record("DeleteBlackout")
+
+	if m.DeleteBlackoutFunc == nil {
+		panic("apimock: ClientAPI.DeleteBlackout called but DeleteBlackoutFunc is not set")
+	}
+
+	return m.DeleteBlackoutFunc(id)
+}
+
+func (m *ClientAPI) GetRetention(id int) (api.Retention, error) {
+	m.record("GetRetention")
+
+	if m.GetRetentionFunc == nil {
+		panic("apimock: ClientAPI.GetRetention called but GetRetentionFunc is not set")
+	}
+
+	return m.GetRetentionFunc(id)
+}
+
+func (m *ClientAPI) CreateRetention(retention api.Retention) (api.Retention, error) {
+	m.record("CreateRetention")
+
+	if m.CreateRetentionFunc == nil {
+		panic("apimock: ClientAPI.CreateRetention called but CreateRetentionFunc is not set")
+	}
+
+	return m.CreateRetentionFunc(retention)
+}
+
+func (m *ClientAPI) UpdateRetention(retention api.Retention) error {
+	m.record("UpdateRetention")
+
+	if m.UpdateRetentionFunc == nil {
+		panic("apimock: ClientAPI.UpdateRetention called but UpdateRetentionFunc is not set")
+	}
+
+	return m.UpdateRetentionFunc(retention)
+}
+
+func (m *ClientAPI) DeleteRetention(id int) error {
+	m.record("DeleteRetention")
+
+	if m.DeleteRetentionFunc == nil {
+		panic("apimock: ClientAPI.DeleteRetention called but DeleteRetentionFunc is not set")
+	}
+
+	return m.DeleteRetentionFunc(id)
+}
+
+func (m *ClientAPI) GetJob(id int) (api.BackupJob, error) {
+	m.record("GetJob")
+
+	if m.GetJobFunc == nil {
+		panic("apimock: ClientAPI.GetJob called but GetJobFunc is not set")
+	}
+
+	return m.GetJobFunc(id)
+}
+
+func (m *ClientAPI) ListJobs() ([]api.BackupJob, error) {
+	m.record("ListJobs")
+
+	if m.ListJobsFunc == nil {
+		panic("apimock: ClientAPI.ListJobs called but ListJobsFunc is not set")
+	}
+
+	return m.ListJobsFunc()
+}
+
+func (m *ClientAPI) CreateJob(job api.BackupJob) (api.BackupJob, error) {
+	m.record("CreateJob")
+
+	if m.CreateJobFunc == nil {
+		panic("apimock: ClientAPI.CreateJob called but CreateJobFunc is not set")
+	}
+
+	return m.CreateJobFunc(job)
+}
+
+func (m *ClientAPI) UpdateJob(job api.BackupJob) error {
+	m.record("UpdateJob")
+
+	if m.UpdateJobFunc == nil {
+		panic("apimock: ClientAPI.UpdateJob called but UpdateJobFunc is not set")
+	}
+
+	return m.UpdateJobFunc(job)
+}
+
+func (m *ClientAPI) DeleteJob(id int) error {
+	m.record("DeleteJob")
+
+	if m.DeleteJobFunc == nil {
+		panic("apimock: ClientAPI.DeleteJob called but DeleteJobFunc is not set")
+	}
+
+	return m.DeleteJobFunc(id)
+}
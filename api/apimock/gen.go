@@ -0,0 +1,201 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ignore
+// +build ignore
+
+// gen writes the mock of api.ClientAPI in client_api.go, run it with
+// 'go generate' after changing the interface
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "../client_api.go", nil, 0)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	iface := findInterface(file, "ClientAPI")
+
+	if iface == nil {
+		log.Fatal("ClientAPI interface not found in ../client_api.go")
+	}
+
+	var fields, methods bytes.Buffer
+
+	for _, m := range iface.Methods.List {
+		fn, ok := m.Type.(*ast.FuncType)
+
+		if !ok || len(m.Names) == 0 {
+			log.Fatalf("Unexpected embedded interface in ClientAPI at %s", fset.Position(m.Pos()))
+		}
+
+		name := m.Names[0].Name
+		params, args := paramList(fn.Params)
+		results := resultList(fn.Results)
+
+		fmt.Fprintf(&fields, "\t%sFunc func(%s) %s\n", name, params, results)
+
+		fmt.Fprintf(&methods, "\nfunc (m *ClientAPI) %s(%s) %s {\n", name, params, results)
+		fmt.Fprintf(&methods, "\tm.record(%q)\n\n", name)
+		fmt.Fprintf(&methods, "\tif m.%sFunc == nil {\n", name)
+		fmt.Fprintf(&methods, "\t\tpanic(\"apimock: ClientAPI.%s called but %sFunc is not set\")\n\t}\n\n", name, name)
+
+		if results == "" {
+			fmt.Fprintf(&methods, "\tm.%sFunc(%s)\n}\n", name, args)
+		} else {
+			fmt.Fprintf(&methods, "\treturn m.%sFunc(%s)\n}\n", name, args)
+		}
+	}
+
+	src := fmt.Sprintf(template, fields.String(), methods.String())
+	formatted, err := format.Source([]byte(src))
+
+	if err != nil {
+		log.Fatalf("%s, while formatting:\n%s", err, src)
+	}
+
+	if err := ioutil.WriteFile("client_api.go", formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func findInterface(file *ast.File, name string) *ast.InterfaceType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+				iface, _ := ts.Type.(*ast.InterfaceType)
+				return iface
+			}
+		}
+	}
+
+	return nil
+}
+
+// paramList returns the method parameters, and the arguments to pass them on
+func paramList(fields *ast.FieldList) (params, args string) {
+	var ps, as []string
+
+	for _, f := range fields.List {
+		for _, n := range f.Names {
+			ps = append(ps, n.Name+" "+typeString(f.Type))
+			as = append(as, n.Name)
+		}
+	}
+
+	return strings.Join(ps, ", "), strings.Join(as, ", ")
+}
+
+func resultList(fields *ast.FieldList) string {
+	if fields == nil || len(fields.List) == 0 {
+		return ""
+	}
+
+	var rs []string
+
+	for _, f := range fields.List {
+		rs = append(rs, typeString(f.Type))
+	}
+
+	if len(rs) == 1 {
+		return rs[0]
+	}
+
+	return "(" + strings.Join(rs, ", ") + ")"
+}
+
+// typeString returns the type as seen from the apimock package
+func typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if ast.IsExported(t.Name) {
+			return "api." + t.Name
+		}
+
+		return t.Name
+	case *ast.ArrayType:
+		return "[]" + typeString(t.Elt)
+	case *ast.StarExpr:
+		return "*" + typeString(t.X)
+	case *ast.MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.InterfaceType:
+		return "interface{}"
+	}
+
+	log.Fatalf("Unsupported type %T in ClientAPI", expr)
+
+	return ""
+}
+
+const template = `// Code generated by gen.go; DO NOT EDIT.
+
+package apimock
+
+import (
+	"sync"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+)
+
+// ClientAPI is a mock of api.ClientAPI. Each method calls the field of the same
+// name with the Func suffix, and panics if it is not set
+type ClientAPI struct {
+	mu    sync.Mutex
+	calls map[string]int
+
+%s}
+
+var _ api.ClientAPI = (*ClientAPI)(nil)
+
+// Calls returns how many times method was called
+func (m *ClientAPI) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[method]
+}
+
+func (m *ClientAPI) record(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+
+	m.calls[method]++
+}
+%s`
@@ -0,0 +1,127 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp is a time sent by the API in ISO 8601 format
+type Timestamp struct {
+	time.Time
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(t.Format(ISO_8601_FORMAT))
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	parsed, err := time.Parse(ISO_8601_FORMAT, s)
+
+	if err != nil {
+		parsed, err = time.Parse(time.RFC3339, s)
+	}
+
+	if err != nil {
+		return wrap("while parsing time "+s, err)
+	}
+
+	t.Time = parsed
+
+	return nil
+}
+
+// Backup is an entry of the backup catalog
+type Backup struct {
+	ID          int       `json:"id"`
+	ServerID    int       `json:"serverId"`
+	StorageID   int       `json:"storageId"`
+	ScheduleID  int       `json:"scheduleId"`
+	RetentionID int       `json:"retentionId"`
+	BackupType  string    `json:"backupType"`
+	Status      string    `json:"status"`
+	Size        int64     `json:"size"`
+	StartedAt   Timestamp `json:"startedAt"`
+	FinishedAt  Timestamp `json:"finishedAt"`
+}
+
+// Duration is how long the backup took, zero if it did not finish
+func (b Backup) Duration() time.Duration {
+	if b.FinishedAt.IsZero() || b.StartedAt.IsZero() {
+		return 0
+	}
+
+	return b.FinishedAt.Sub(b.StartedAt.Time)
+}
+
+// Succeeded reports if the backup finished successfully and can be restored.
+// Catalog entries without status are taken as successful
+func (b Backup) Succeeded() bool {
+	switch strings.ToLower(b.Status) {
+	case "", "ok", "success", "succeeded", "successful", "completed", "finished":
+		return true
+	}
+
+	return false
+}
+
+func (b Backup) IsFull() bool {
+	return b.BackupType == "" || strings.EqualFold(b.BackupType, "full")
+}
+
+// BackupFilter restricts the backups returned by ListBackups, zero values
+// are ignored
+type BackupFilter struct {
+	ServerID  int
+	StorageID int
+}
+
+// ListBackups returns the backups in the catalog, whatever their status. Use
+// Backup.Succeeded to tell the ones that can be restored
+func (c *Client) ListBackups(filter BackupFilter) (backups []Backup, err error) {
+	query := url.Values{}
+
+	if filter.ServerID > 0 {
+		query.Set("serverId", strconv.Itoa(filter.ServerID))
+	}
+
+	if filter.StorageID > 0 {
+		query.Set("storageId", strconv.Itoa(filter.StorageID))
+	}
+
+	u := c.host + "/backups"
+
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	err = c.httpClient.getJSON(u, &backups)
+
+	return
+}
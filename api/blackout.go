@@ -0,0 +1,270 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Blackout is a period where backups must not run, attached either to a
+// schedule or to a server
+type Blackout struct {
+	ID           int          `json:"id"`
+	Reason       string       `json:"reason"`
+	BlackoutType BlackoutType `json:"blackoutType"`
+	ScheduleID   int          `json:"scheduleId,omitempty"`
+	ServerID     int          `json:"serverId,omitempty"`
+
+	// date range blackouts, from Start (inclusive) to End (exclusive)
+	Start Timestamp `json:"start"`
+	End   Timestamp `json:"end"`
+
+	// weekly blackouts, from StartTime to EndTime (HH:MM) every day in Weekdays
+	// (comma separated, 0 being Sunday). If EndTime is before StartTime the
+	// window ends the next day
+	Weekdays  string `json:"weekdays,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+
+	// Timezone is the IANA time zone of the weekly window times, UTC if empty
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type BlackoutType int
+
+const (
+	BLACKOUT_DATE_RANGE BlackoutType = 1
+	BLACKOUT_WEEKLY     BlackoutType = 2
+)
+
+func (b BlackoutType) String() string {
+	switch b {
+	case BLACKOUT_DATE_RANGE:
+		return "Date Range"
+	case BLACKOUT_WEEKLY:
+		return "Weekly"
+	}
+
+	return "Unknown"
+}
+
+func (b Blackout) location() (*time.Location, error) {
+	if b.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(b.Timezone)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unknown timezone '%s', expected an IANA name like 'America/New_York'", b.Timezone)
+	}
+
+	return loc, nil
+}
+
+// Validate checks the blackout before sending it to the API
+func (b Blackout) Validate() error {
+	if (b.ScheduleID > 0) == (b.ServerID > 0) {
+		return fmt.Errorf("Blackout must be attached to either a schedule or a server")
+	}
+
+	if _, err := b.location(); err != nil {
+		return err
+	}
+
+	switch b.BlackoutType {
+	case BLACKOUT_DATE_RANGE:
+		if b.Start.IsZero() || b.End.IsZero() {
+			return fmt.Errorf("Date range blackouts need a start and an end")
+		}
+
+		if !b.End.After(b.Start.Time) {
+			return fmt.Errorf("Blackout end %s must be after its start %s",
+				b.End.Format(time.RFC3339), b.Start.Format(time.RFC3339))
+		}
+
+	case BLACKOUT_WEEKLY:
+		if _, err := ParseWeekdays(b.Weekdays); err != nil {
+			return err
+		}
+
+		start, err := parseTimeOfDay(b.StartTime)
+
+		if err != nil {
+			return wrap("in blackout start time", err)
+		}
+
+		end, err := parseTimeOfDay(b.EndTime)
+
+		if err != nil {
+			return wrap("in blackout end time", err)
+		}
+
+		if start == end {
+			return fmt.Errorf("Blackout start and end times cannot be equal")
+		}
+
+	default:
+		return fmt.Errorf("Blackout type %d not recognized", int(b.BlackoutType))
+	}
+
+	return nil
+}
+
+// Contains reports if t falls inside the blackout
+func (b Blackout) Contains(t time.Time) bool {
+	switch b.BlackoutType {
+	case BLACKOUT_DATE_RANGE:
+		return !t.Before(b.Start.Time) && t.Before(b.End.Time)
+
+	case BLACKOUT_WEEKLY:
+		loc, err := b.location()
+		weekdays, err2 := ParseWeekdays(b.Weekdays)
+		start, err3 := parseTimeOfDay(b.StartTime)
+		end, err4 := parseTimeOfDay(b.EndTime)
+
+		if err != nil || err2 != nil || err3 != nil || err4 != nil {
+			return false
+		}
+
+		local := t.In(loc)
+		minute := local.Hour()*60 + local.Minute()
+		startMinute, endMinute := start.Hour*60+start.Minute, end.Hour*60+end.Minute
+
+		for _, d := range weekdays {
+			if endMinute > startMinute {
+				if local.Weekday() == d && minute >= startMinute && minute < endMinute {
+					return true
+				}
+
+				continue
+			}
+
+			// the window crosses midnight into the next day
+			if (local.Weekday() == d && minute >= startMinute) ||
+				(local.Weekday() == (d+1)%7 && minute < endMinute) {
+
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (b Blackout) String() string {
+	attached := fmt.Sprintf("Schedule ID: %d", b.ScheduleID)
+
+	if b.ServerID > 0 {
+		attached = fmt.Sprintf("Server ID: %d", b.ServerID)
+	}
+
+	out := fmt.Sprintf("ID: %d\nReason: %s\n%s\nBlackout Type: %s", b.ID, b.Reason, attached, b.BlackoutType)
+
+	switch b.BlackoutType {
+	case BLACKOUT_DATE_RANGE:
+		out += fmt.Sprintf("\nStart: %s\nEnd: %s", b.Start.Format(time.RFC3339), b.End.Format(time.RFC3339))
+
+	case BLACKOUT_WEEKLY:
+		out += fmt.Sprintf("\nDays: %s\nFrom: %s\nTo: %s", b.Weekdays, b.StartTime, b.EndTime)
+
+		if b.Timezone != "" {
+			out += "\nTimezone: " + b.Timezone
+		}
+	}
+
+	return out
+}
+
+func (b Blackout) JSONString() string {
+	bs, _ := json.Marshal(b)
+
+	return string(bs)
+}
+
+// BlackoutFilter restricts the blackouts returned by ListBlackouts, zero
+// values are ignored
+type BlackoutFilter struct {
+	ScheduleID int
+	ServerID   int
+}
+
+func (c *Client) ListBlackouts(filter BlackoutFilter) (blackouts []Blackout, err error) {
+	query := url.Values{}
+
+	if filter.ScheduleID > 0 {
+		query.Set("scheduleId", strconv.Itoa(filter.ScheduleID))
+	}
+
+	if filter.ServerID > 0 {
+		query.Set("serverId", strconv.Itoa(filter.ServerID))
+	}
+
+	u := c.host + "/blackouts"
+
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	err = c.httpClient.getJSON(u, &blackouts)
+
+	return
+}
+
+func (c *Client) CreateBlackout(blackout Blackout) (Blackout, error) {
+	blackout.ID = 0
+	blackout.Reason = strings.TrimSpace(blackout.Reason)
+
+	if err := blackout.Validate(); err != nil {
+		return blackout, err
+	}
+
+	val, err := c.httpClient.postJSON(c.host+"/blackouts", blackout)
+
+	if err != nil {
+		return blackout, wrap("while doing client post", err)
+	}
+
+	if id, ok := val["id"]; ok {
+		if intID, ok2 := id.(float64); ok2 { //json marshalling converts ints to floats
+			blackout.ID = int(intID)
+		}
+	}
+
+	if blackout.ID <= 0 {
+		err = fmt.Errorf("Missing ID from blackout response %v", val)
+	}
+
+	return blackout, err
+}
+
+func (c *Client) DeleteBlackout(id int) error {
+	return c.httpClient.deleteJSON(c.host + "/blackouts/" + strconv.Itoa(id))
+}
+
+// inBlackout returns the first blackout containing t, if any
+func inBlackout(t time.Time, blackouts []Blackout) (Blackout, bool) {
+	for _, b := range blackouts {
+		if b.Contains(t) {
+			return b, true
+		}
+	}
+
+	return Blackout{}, false
+}
@@ -16,8 +16,10 @@ package api
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"path"
+	"time"
 )
 
 var (
@@ -28,10 +30,66 @@ var (
 
 type Client struct {
 	host       string
-	httpClient *signedHTTPClient
+	httpClient *SignedHTTPClient
+
+	// timeout and transport are set by WithTimeout and WithTransport, and
+	// applied after every option so WithHTTPClient can't drop them
+	timeout   *time.Duration
+	transport http.RoundTripper
 }
 
-func NewAPIClient(host, accessKey, accessSecret string) (*Client, error) {
+// Option configures a Client created with NewAPIClient
+type Option func(*Client)
+
+// WithTimeout sets the timeout of every API request, 10 seconds by default.
+// It applies on top of WithHTTPClient, whatever the order of the options
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = &timeout
+	}
+}
+
+// WithTransport sets the transport used to send the API requests, for ex: to
+// use a proxy or custom TLS settings. It applies on top of WithHTTPClient,
+// whatever the order of the options
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithHTTPClient sends the API requests with a copy of client, keeping its
+// transport, timeout, cookies and redirect policy, unless WithTransport or
+// WithTimeout are also given. Requests are still signed. A nil client is ignored
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		if client != nil {
+			c.httpClient.Client = *client
+		}
+	}
+}
+
+// WithLogger sends a message to logger for every API request, with its
+// method, URL, status, duration and request ID. A nil logger disables logging
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+
+		c.httpClient.Logger = logger
+	}
+}
+
+// WithDumpHTTP also logs every request and response at LOG_DEBUG level, with
+// the signature headers and secret fields redacted
+func WithDumpHTTP() Option {
+	return func(c *Client) {
+		c.httpClient.DumpHTTP = true
+	}
+}
+
+func NewAPIClient(host, accessKey, accessSecret string, options ...Option) (*Client, error) {
 	if host == "" {
 		return nil, fmt.Errorf("API Host cannot be empty")
 	}
@@ -51,10 +109,23 @@ func NewAPIClient(host, accessKey, accessSecret string) (*Client, error) {
 	u.Scheme = "https"
 	u.Path = path.Join(u.Path, "api")
 
-	return &Client{
+	c := &Client{
 		host:       u.String(),
 		httpClient: NewSignedHTTPClient(accessKey, accessSecret, 10), //default 10 secs timeout
-	}, nil
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.timeout != nil {
+		c.httpClient.Timeout = *c.timeout
+	}
+	if c.transport != nil {
+		c.httpClient.Transport = c.transport
+	}
+
+	return c, nil
 }
 
 func (c *Client) GetBackupKeys() (body []byte, err error) {
@@ -0,0 +1,64 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+// ClientAPI is every call of the Binlogic CloudBackup API. It is implemented by
+// Client, and by apimock.ClientAPI for tests
+type ClientAPI interface {
+	GetBackupKeys() ([]byte, error)
+	ListBackups(filter BackupFilter) ([]Backup, error)
+
+	ListHolds(backupID int) ([]BackupHold, error)
+	CreateHold(hold BackupHold) (BackupHold, error)
+	DeleteHold(id int) error
+
+	GetServer(id int) (Server, error)
+	ListServers() ([]Server, error)
+	CreateServer(name string, dbType DatabaseType, readonly bool, dbHost, dbPort, dbUser, dbPass string) (Server, error)
+	UpdateServer(server Server) error
+	DeleteServer(id int) error
+	GetServerStatus(id int) (ServerStatus, error)
+	GetServerInstall(id int) (Script, error)
+	GetServerUninstall(id int) (Script, error)
+	GetServerUpgrade(id int) (Script, error)
+
+	GetStorage(id int) (Storage, error)
+	ListStorages() ([]Storage, error)
+	CreateStorage(storage Storage) (Storage, error)
+	UpdateStorage(storage Storage) error
+	DeleteStorage(id int) error
+
+	GetSchedule(id int) (Schedule, error)
+	ListSchedules() ([]Schedule, error)
+	CreateSchedule(name string, scheduleType ScheduleType, hours, days, cron, timezone string) (Schedule, error)
+	UpdateSchedule(schedule Schedule) error
+	DeleteSchedule(id int) error
+
+	ListBlackouts(filter BlackoutFilter) ([]Blackout, error)
+	CreateBlackout(blackout Blackout) (Blackout, error)
+	DeleteBlackout(id int) error
+
+	GetRetention(id int) (Retention, error)
+	CreateRetention(retention Retention) (Retention, error)
+	UpdateRetention(retention Retention) error
+	DeleteRetention(id int) error
+
+	GetJob(id int) (BackupJob, error)
+	ListJobs() ([]BackupJob, error)
+	CreateJob(job BackupJob) (BackupJob, error)
+	UpdateJob(job BackupJob) error
+	DeleteJob(id int) error
+}
+
+var _ ClientAPI = (*Client)(nil)
@@ -0,0 +1,63 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testTransport struct{}
+
+func (testTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestClientOptionsOrder(t *testing.T) {
+	transport := testTransport{}
+	base := &http.Client{Timeout: time.Minute}
+
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"http client first", []Option{WithHTTPClient(base), WithTransport(transport), WithTimeout(time.Second)}},
+		{"http client last", []Option{WithTransport(transport), WithTimeout(time.Second), WithHTTPClient(base)}},
+		{"http client between", []Option{WithTransport(transport), WithHTTPClient(base), WithTimeout(time.Second)}},
+	}
+
+	for _, tt := range tests {
+		c, err := NewAPIClient("example.com", "key", "secret", tt.options...)
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+		if c.httpClient.Timeout != time.Second {
+			t.Errorf("%s: got timeout %s, want %s", tt.name, c.httpClient.Timeout, time.Second)
+		}
+		if c.httpClient.Transport != transport {
+			t.Errorf("%s: got transport %v, want the WithTransport one", tt.name, c.httpClient.Transport)
+		}
+	}
+
+	c, err := NewAPIClient("example.com", "key", "secret", WithHTTPClient(base))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.httpClient.Timeout != time.Minute {
+		t.Errorf("got timeout %s, want the http client's %s", c.httpClient.Timeout, time.Minute)
+	}
+}
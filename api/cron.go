@@ -0,0 +1,259 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed standard 5 field cron expression:
+// minute hour day-of-month month day-of-week
+type CronSchedule struct {
+	expr    string
+	minutes uint64 // bits 0-59
+	hours   uint64 // bits 0-23
+	dom     uint64 // bits 1-31
+	months  uint64 // bits 1-12
+	dow     uint64 // bits 0-6, 0 being Sunday
+
+	// as in vixie cron, when both day fields are restricted a day matches if
+	// either of them matches
+	domRestricted bool
+	dowRestricted bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names for min, min+1... if the field accepts them
+}
+
+var (
+	cronMinute = cronField{"minute", 0, 59, nil}
+	cronHour   = cronField{"hour", 0, 23, nil}
+	cronDom    = cronField{"day-of-month", 1, 31, nil}
+	cronMonth  = cronField{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun",
+		"jul", "aug", "sep", "oct", "nov", "dec"}}
+
+	// day-of-week accepts 7 as Sunday too, folded into 0 after parsing
+	cronDow = cronField{"day-of-week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+
+	cronMacros = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// ParseCron parses a 5 field cron expression. Each field accepts '*', single
+// values, ranges 'a-b', lists 'a,b,c' and steps '*/n' or 'a-b/n'. Months and
+// days of week accept 3 letter english names, and @yearly, @monthly, @weekly,
+// @daily and @hourly are accepted as shortcuts
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Cron expression '%s' must have 5 fields (minute hour day-of-month "+
+			"month day-of-week), got %d", expr, len(fields))
+	}
+
+	c := &CronSchedule{expr: expr}
+
+	var err error
+
+	if c.minutes, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if c.hours, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if c.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if c.months, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if c.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	if c.domRestricted && !c.dowRestricted && !c.domReachable() {
+		return nil, fmt.Errorf("Cron expression '%s' never runs, the day-of-month does not exist "+
+			"in any of the months", expr)
+	}
+
+	return c, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, fmt.Errorf("Empty value in %s field '%s'", f.name, field)
+		}
+
+		rng, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+
+			n, err := strconv.Atoi(part[i+1:])
+
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("Invalid step '%s' in %s field '%s'", part[i+1:], f.name, field)
+			}
+
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+
+			var err error
+
+			if lo, err = f.value(bounds[0], field); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1], field); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 'a/n' means from a to the end of the range, every n
+				hi = f.max
+			}
+
+			if lo > hi {
+				return 0, fmt.Errorf("Invalid range '%s' in %s field '%s', start is after end",
+					rng, f.name, field)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) value(s, field string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid value '%s' in %s field '%s'", s, f.name, field)
+	}
+
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("Value %d out of range %d-%d in %s field '%s'", v, f.min, f.max, f.name, field)
+	}
+
+	return v, nil
+}
+
+// domReachable checks that some selected day of month exists in some
+// selected month, to reject expressions like '0 0 30 2 *'
+func (c *CronSchedule) domReachable() bool {
+	daysIn := [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+	for m := 1; m <= 12; m++ {
+		if c.months&(1<<uint(m)) == 0 {
+			continue
+		}
+
+		for d := 1; d <= daysIn[m]; d++ {
+			if c.dom&(1<<uint(d)) != 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *CronSchedule) String() string {
+	return c.expr
+}
+
+func (c *CronSchedule) matchDay(t time.Time) bool {
+	if c.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domRestricted && c.dowRestricted {
+		return domMatch || dowMatch
+	}
+
+	return domMatch && dowMatch
+}
+
+// Next returns the first run strictly after the given time, evaluated in the
+// wall clock of its location (see nextWallClock for DST handling). It returns
+// the zero time if there is no next run
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	var times []TimeOfDay
+
+	for h := 0; h < 24; h++ {
+		if c.hours&(1<<uint(h)) == 0 {
+			continue
+		}
+
+		for m := 0; m < 60; m++ {
+			if c.minutes&(1<<uint(m)) != 0 {
+				times = append(times, TimeOfDay{h, m})
+			}
+		}
+	}
+
+	return nextWallClock(after, c.matchDay, times)
+}
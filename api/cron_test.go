@@ -0,0 +1,163 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * * *", ""},
+		{"  0 3 * * *  ", ""},
+		{"0 0 30 2 mon", ""},
+		{"", "must have 5 fields"},
+		{"* * * *", "must have 5 fields"},
+		{"* * * * * *", "must have 5 fields"},
+		{"@reboot", "must have 5 fields"},
+		{"60 * * * *", "Value 60 out of range 0-59 in minute field"},
+		{"* 24 * * *", "Value 24 out of range 0-23 in hour field"},
+		{"* * 0 * *", "Value 0 out of range 1-31 in day-of-month field"},
+		{"* * 32 * *", "Value 32 out of range 1-31 in day-of-month field"},
+		{"* * * 13 *", "Value 13 out of range 1-12 in month field"},
+		{"* * * * 8", "Value 8 out of range 0-7 in day-of-week field"},
+		{"* * * foo *", "Invalid value 'foo' in month field"},
+		{"* * * * sunday", "Invalid value 'sunday' in day-of-week field"},
+		{"* * * jan *", ""},
+		{"* * * * mon-", "Invalid value '' in day-of-week field"},
+		{"1,,2 * * * *", "Empty value in minute field"},
+		{"1, * * * *", "Empty value in minute field"},
+		{"*/0 * * * *", "Invalid step '0' in minute field"},
+		{"*/x * * * *", "Invalid step 'x' in minute field"},
+		{"*/-1 * * * *", "Invalid step '-1' in minute field"},
+		{"5-1 * * * *", "start is after end"},
+		{"* * * dec-jan *", "start is after end"},
+		{"0 0 30 2 *", "never runs"},
+		{"0 0 31 4,6,9,11 *", "never runs"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("ParseCron(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Saturday
+	after := time.Date(2018, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"every minute", "* * * * *", "2018-03-10T10:01:00Z"},
+		{"minute", "30 * * * *", "2018-03-10T10:30:00Z"},
+		{"hour", "0 9 * * *", "2018-03-11T09:00:00Z"},
+		{"range", "0 9-17 * * *", "2018-03-10T11:00:00Z"},
+		{"list", "0,45 10 * * *", "2018-03-10T10:45:00Z"},
+		{"step", "*/15 * * * *", "2018-03-10T10:15:00Z"},
+		{"range step", "10-50/20 * * * *", "2018-03-10T10:10:00Z"},
+		{"start step", "5/20 * * * *", "2018-03-10T10:05:00Z"},
+		{"hour step", "0 */5 * * *", "2018-03-10T15:00:00Z"},
+		{"day of month", "0 12 31 * *", "2018-03-31T12:00:00Z"},
+		{"month", "0 0 1 6 *", "2018-06-01T00:00:00Z"},
+		{"month names", "0 0 * mar,jun *", "2018-03-11T00:00:00Z"},
+		{"month name upper case", "0 0 1 JAN *", "2019-01-01T00:00:00Z"},
+		{"month name range", "0 0 1 may-aug *", "2018-05-01T00:00:00Z"},
+		{"day of week", "0 0 * * 1", "2018-03-12T00:00:00Z"},
+		{"day of week name", "0 0 * * sat", "2018-03-17T00:00:00Z"},
+		{"day of week range", "0 0 * * mon-fri", "2018-03-12T00:00:00Z"},
+		{"sunday as 0", "0 0 * * 0", "2018-03-11T00:00:00Z"},
+		{"sunday as 7", "0 0 * * 7", "2018-03-11T00:00:00Z"},
+		{"days of month or week", "0 0 13 * fri", "2018-03-13T00:00:00Z"},
+		{"days of week or month", "0 0 16 * tue", "2018-03-13T00:00:00Z"},
+		{"day of month step and week", "0 0 */2 * mon", "2018-03-19T00:00:00Z"},
+		{"unreachable day of month with week", "0 0 30 2 mon", "2019-02-04T00:00:00Z"},
+		{"leap day", "0 0 29 2 *", "2020-02-29T00:00:00Z"},
+		{"@yearly", "@yearly", "2019-01-01T00:00:00Z"},
+		{"@annually", "@annually", "2019-01-01T00:00:00Z"},
+		{"@monthly", "@monthly", "2018-04-01T00:00:00Z"},
+		{"@weekly", "@weekly", "2018-03-11T00:00:00Z"},
+		{"@daily", "@daily", "2018-03-11T00:00:00Z"},
+		{"@midnight upper case", "@MIDNIGHT", "2018-03-11T00:00:00Z"},
+		{"@hourly", "@hourly", "2018-03-10T11:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+
+		if err != nil {
+			t.Errorf("%s: ParseCron(%q) unexpected error %v", tt.name, tt.expr, err)
+			continue
+		}
+
+		if got := c.Next(after).Format(time.RFC3339); got != tt.want {
+			t.Errorf("%s: ParseCron(%q).Next() = %s, want %s", tt.name, tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clocks go from 02:00 EST to 03:00 EDT on 2018-03-11, and from 02:00 EDT
+	// back to 01:00 EST on 2018-11-04
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  string
+	}{
+		{"spring skipped time", "30 2 * * *", time.Date(2018, time.March, 10, 12, 0, 0, 0, loc),
+			"2018-03-12T02:30:00-04:00"},
+		{"spring time after the gap", "0 3 * * *", time.Date(2018, time.March, 10, 12, 0, 0, 0, loc),
+			"2018-03-11T03:00:00-04:00"},
+		{"spring steps across the gap", "*/30 * * * *", time.Date(2018, time.March, 11, 1, 45, 0, 0, loc),
+			"2018-03-11T03:00:00-04:00"},
+		{"fall repeated time runs first", "30 1 * * *", time.Date(2018, time.November, 3, 12, 0, 0, 0, loc),
+			"2018-11-04T01:30:00-04:00"},
+		{"fall repeated time runs once", "30 1 * * *", time.Date(2018, time.November, 4, 1, 30, 0, 0, loc),
+			"2018-11-05T01:30:00-05:00"},
+		{"fall steps skip the repeated hour", "*/30 * * * *", time.Date(2018, time.November, 4, 1, 45, 0, 0, loc),
+			"2018-11-04T02:00:00-05:00"},
+		{"fall steps from the repeated hour", "*/30 * * * *",
+			time.Date(2018, time.November, 4, 6, 15, 0, 0, time.UTC).In(loc), "2018-11-04T02:00:00-05:00"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+
+		if err != nil {
+			t.Errorf("%s: ParseCron(%q) unexpected error %v", tt.name, tt.expr, err)
+			continue
+		}
+
+		if got := c.Next(tt.after).Format(time.RFC3339); got != tt.want {
+			t.Errorf("%s: ParseCron(%q).Next(%s) = %s, want %s", tt.name, tt.expr,
+				tt.after.Format(time.RFC3339), got, tt.want)
+		}
+	}
+}
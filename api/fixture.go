@@ -0,0 +1,259 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fixture is a request to the API and its response, saved by the record
+// transport and served back by the replay transport
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+type FixtureRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type FixtureResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// sanitizeBody redacts the secret fields of JSON bodies. Other bodies, like
+// the server scripts which embed the agent credentials, are left out
+func sanitizeBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("[%d bytes not recorded]", len(body))
+	}
+
+	bs, _ := json.Marshal(redactJSON(v))
+
+	return string(bs)
+}
+
+func sanitizeHeader(header http.Header) http.Header {
+	clean := http.Header{}
+
+	for name, values := range header {
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			values = []string{redacted}
+		}
+
+		clean[name] = values
+	}
+
+	return clean
+}
+
+// matches reports if the request is the one recorded in the fixture. The host
+// and the headers, which hold the date and signature, are ignored
+func (f Fixture) matches(req *http.Request, body string) bool {
+	u, err := req.URL.Parse(f.Request.URL)
+
+	return err == nil && f.Request.Method == req.Method && u.Path == req.URL.Path &&
+		u.Query().Encode() == req.URL.Query().Encode() && f.Request.Body == body
+}
+
+type recordTransport struct {
+	mu   sync.Mutex
+	dir  string
+	next http.RoundTripper
+	seq  int
+}
+
+var fixtureNameChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// NewRecordTransport saves every request sent through next, and its response,
+// as a JSON fixture in dir, with the signature headers and secret fields
+// redacted. If next is nil http.DefaultTransport is used. dir must not have
+// fixtures already, as replaying would mix them up with the new ones
+func NewRecordTransport(dir string, next http.RoundTripper) (http.RoundTripper, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, wrap("while creating the fixtures directory", err)
+	}
+
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("Fixtures directory %s is not empty, record into a new directory", dir)
+	}
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &recordTransport{dir: dir, next: next}, nil
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request of its caller
+	clone := *req
+	reqBody := readBody(&clone.Body)
+	resp, err := t.next.RoundTrip(&clone)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the length changes if secrets are redacted
+	respHeader := sanitizeHeader(resp.Header)
+	respHeader.Del("Content-Length")
+
+	fixture := Fixture{
+		Request: FixtureRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: sanitizeHeader(req.Header),
+			Body:   sanitizeBody(reqBody),
+		},
+		Response: FixtureResponse{
+			StatusCode: resp.StatusCode,
+			Header:     respHeader,
+			Body:       sanitizeBody(readBody(&resp.Body)),
+		},
+	}
+
+	bs, err := json.MarshalIndent(fixture, "", "  ")
+
+	if err != nil {
+		return nil, wrap("while marshalling fixture", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	name := fmt.Sprintf("%04d-%s-%s.json", t.seq, req.Method,
+		strings.Trim(fixtureNameChars.ReplaceAllString(req.URL.Path, "-"), "-"))
+
+	if err := ioutil.WriteFile(filepath.Join(t.dir, name), bs, 0600); err != nil {
+		return nil, wrap("while writing fixture "+name, err)
+	}
+
+	return resp, nil
+}
+
+type replayTransport struct {
+	mu       sync.Mutex
+	dir      string
+	fixtures []Fixture
+	used     []bool
+}
+
+// NewReplayTransport serves the responses of the fixtures saved in dir by
+// NewRecordTransport, without sending anything to the network. Requests are
+// matched by method, path, query and body, and repeated requests get their
+// responses in the recorded order
+func NewReplayTransport(dir string) (http.RoundTripper, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No fixtures found in %s", dir)
+	}
+
+	sort.Strings(files)
+
+	t := &replayTransport{dir: dir, used: make([]bool, len(files))}
+
+	for _, file := range files {
+		bs, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var f Fixture
+
+		if err := json.Unmarshal(bs, &f); err != nil {
+			return nil, wrap("while reading fixture "+file, err)
+		}
+
+		t.fixtures = append(t.fixtures, f)
+	}
+
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := *req
+	body := sanitizeBody(readBody(&clone.Body))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	found := -1
+
+	// the first unused match, or the last match if they were all used
+	for i, f := range t.fixtures {
+		if !f.matches(req, body) {
+			continue
+		}
+
+		found = i
+
+		if !t.used[i] {
+			break
+		}
+	}
+
+	if found < 0 {
+		return nil, fmt.Errorf("No fixture in %s for %s %s", t.dir, req.Method, req.URL.RequestURI())
+	}
+
+	t.used[found] = true
+	f := t.fixtures[found].Response
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.StatusCode, http.StatusText(f.StatusCode)),
+		StatusCode:    f.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
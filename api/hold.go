@@ -0,0 +1,144 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupHold pins a backup so retention policies never delete it, for ex: for
+// a legal or audit requirement
+type BackupHold struct {
+	ID       int       `json:"id"`
+	BackupID int       `json:"backupId"`
+	Reason   string    `json:"reason"`
+	PlacedBy string    `json:"placedBy"`
+	PlacedAt Timestamp `json:"placedAt"`
+
+	// Until is when the hold expires, zero for an indefinite hold
+	Until Timestamp `json:"until"`
+}
+
+// Active reports if the hold still protects its backup at t
+func (h BackupHold) Active(t time.Time) bool {
+	return h.Until.IsZero() || t.Before(h.Until.Time)
+}
+
+// Validate checks the hold before sending it to the API
+func (h BackupHold) Validate() error {
+	if h.BackupID <= 0 {
+		return fmt.Errorf("Invalid backup ID %d for hold", h.BackupID)
+	}
+
+	if strings.TrimSpace(h.Reason) == "" {
+		return fmt.Errorf("Hold reason cannot be empty")
+	}
+
+	if strings.TrimSpace(h.PlacedBy) == "" {
+		return fmt.Errorf("Hold must record who placed it")
+	}
+
+	if !h.Until.IsZero() && !h.Until.After(h.PlacedAt.Time) {
+		return fmt.Errorf("Hold expiration %s must be in the future", h.Until.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (h BackupHold) String() string {
+	until := "indefinite"
+
+	if !h.Until.IsZero() {
+		until = h.Until.Format(time.RFC3339)
+	}
+
+	return fmt.Sprintf("ID: %d\nBackup ID: %d\nReason: %s\nPlaced By: %s\nPlaced At: %s\nUntil: %s",
+		h.ID, h.BackupID, h.Reason, h.PlacedBy, h.PlacedAt.Format(time.RFC3339), until)
+}
+
+func (h BackupHold) JSONString() string {
+	bs, _ := json.Marshal(h)
+
+	return string(bs)
+}
+
+// ListHolds returns the holds of a backup, or of every backup if backupID is
+// zero. Expired holds are included
+func (c *Client) ListHolds(backupID int) (holds []BackupHold, err error) {
+	u := c.host + "/holds"
+
+	if backupID > 0 {
+		u += "?" + url.Values{"backupId": {strconv.Itoa(backupID)}}.Encode()
+	}
+
+	err = c.httpClient.getJSON(u, &holds)
+
+	return
+}
+
+// CreateHold places a hold on a backup. If PlacedAt is not set it is now
+func (c *Client) CreateHold(hold BackupHold) (BackupHold, error) {
+	hold.ID = 0
+	hold.Reason = strings.TrimSpace(hold.Reason)
+
+	if hold.PlacedAt.IsZero() {
+		hold.PlacedAt = Timestamp{time.Now().UTC()}
+	}
+
+	if err := hold.Validate(); err != nil {
+		return hold, err
+	}
+
+	val, err := c.httpClient.postJSON(c.host+"/holds", hold)
+
+	if err != nil {
+		return hold, wrap("while doing client post", err)
+	}
+
+	if id, ok := val["id"]; ok {
+		if intID, ok2 := id.(float64); ok2 { //json marshalling converts ints to floats
+			hold.ID = int(intID)
+		}
+	}
+
+	if hold.ID <= 0 {
+		err = fmt.Errorf("Missing ID from hold response %v", val)
+	}
+
+	return hold, err
+}
+
+// DeleteHold releases a hold, the backup is subject to retention again
+// unless it has other active holds
+func (c *Client) DeleteHold(id int) error {
+	return c.httpClient.deleteJSON(c.host + "/holds/" + strconv.Itoa(id))
+}
+
+// heldBackups returns the IDs of the backups with an active hold at t
+func heldBackups(holds []BackupHold, t time.Time) map[int]bool {
+	held := map[int]bool{}
+
+	for _, h := range holds {
+		if h.Active(t) {
+			held[h.BackupID] = true
+		}
+	}
+
+	return held
+}
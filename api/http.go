@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -18,37 +19,47 @@ import (
 
 const ISO_8601_FORMAT = "2006-01-02T15:04:05-0700"
 
-type signedHTTPClient struct {
+// REQUEST_ID_HEADER identifies every request sent to the API, to find it in
+// the logs of both sides
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+type SignedHTTPClient struct {
 	http.Client
 	AccessKey string
 	SecretKey string
+
+	// Logger receives a message for every request, and the request and
+	// response dumps if DumpHTTP is set
+	Logger   Logger
+	DumpHTTP bool
 }
 
-func NewSignedHTTPClient(accessKey, secretKey string, timeoutSecs int) *signedHTTPClient {
-	return &signedHTTPClient{
+func NewSignedHTTPClient(accessKey, secretKey string, timeoutSecs int) *SignedHTTPClient {
+	return &SignedHTTPClient{
 		Client: http.Client{
 			Timeout: time.Second * time.Duration(timeoutSecs),
 		},
 		AccessKey: accessKey,
 		SecretKey: secretKey,
+		Logger:    nopLogger{},
 	}
 }
 
-func (cli *signedHTTPClient) SignedGet(url string, headers map[string]string) (*http.Response, error) {
+func (cli *SignedHTTPClient) SignedGet(url string, headers map[string]string) (*http.Response, error) {
 	return cli.SignedDo("GET", url, nil, headers)
 }
 
-func (cli *signedHTTPClient) SignedDelete(url string, headers map[string]string) (*http.Response, error) {
+func (cli *SignedHTTPClient) SignedDelete(url string, headers map[string]string) (*http.Response, error) {
 	return cli.SignedDo("DELETE", url, nil, headers)
 }
 
-func (cli *signedHTTPClient) SignedPost(url string, body io.Reader,
+func (cli *SignedHTTPClient) SignedPost(url string, body io.Reader,
 	headers map[string]string) (*http.Response, error) {
 
 	return cli.SignedDo("POST", url, body, headers)
 }
 
-func (cli *signedHTTPClient) SignedDo(verb, url string, body io.Reader,
+func (cli *SignedHTTPClient) SignedDo(verb, url string, body io.Reader,
 	headers map[string]string) (*http.Response, error) {
 
 	req, err := http.NewRequest(verb, url, body)
@@ -63,18 +74,43 @@ func (cli *signedHTTPClient) SignedDo(verb, url string, body io.Reader,
 		}
 	}
 
+	requestID := newRequestID()
+	req.Header.Set(REQUEST_ID_HEADER, requestID)
+
 	cli.sign(req, body)
 
+	if cli.DumpHTTP {
+		cli.Logger.Log(LOG_DEBUG, "http request", "request_id", requestID, "dump", dumpRequest(req))
+	}
+
+	start := time.Now()
 	resp, err := cli.Do(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
 
 	if err != nil {
+		cli.Logger.Log(LOG_ERROR, "request failed", "method", verb, "url", url, "request_id", requestID,
+			"duration", elapsed, "error", err)
+
 		return nil, err
 	}
 
+	level := LOG_INFO
+
+	if resp.StatusCode/100 != 2 {
+		level = LOG_WARN
+	}
+
+	cli.Logger.Log(level, "request done", "method", verb, "url", url, "status", resp.StatusCode,
+		"request_id", requestID, "duration", elapsed)
+
+	if cli.DumpHTTP {
+		cli.Logger.Log(LOG_DEBUG, "http response", "request_id", requestID, "dump", dumpResponse(resp))
+	}
+
 	return resp, nil
 }
 
-func (cli *signedHTTPClient) sign(req *http.Request, body io.Reader) error {
+func (cli *SignedHTTPClient) sign(req *http.Request, body io.Reader) error {
 	var buff bytes.Buffer
 
 	date := time.Now().UTC().Format(ISO_8601_FORMAT)
@@ -118,7 +154,7 @@ func (cli *signedHTTPClient) sign(req *http.Request, body io.Reader) error {
 	return nil
 }
 
-func (cli *signedHTTPClient) postJSON(url string, i interface{}) (val map[string]interface{}, err error) {
+func (cli *SignedHTTPClient) postJSON(url string, i interface{}) (val map[string]interface{}, err error) {
 	b, err := json.Marshal(i)
 
 	if err != nil {
@@ -145,7 +181,68 @@ func (cli *signedHTTPClient) postJSON(url string, i interface{}) (val map[string
 	return
 }
 
-func (cli *signedHTTPClient) parseResponseJSON(resp *http.Response) (body []byte, val map[string]interface{}, err error) {
+// getJSON does a signed get and decodes the response into v, using the API
+// error message when the response is not HTTP 2xx
+func (cli *SignedHTTPClient) getJSON(url string, v interface{}) error {
+	resp, err := cli.SignedGet(url, defaultHeaders)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return wrap("while reading body", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		if _, err := cli.isResponseOk(body); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("API returned HTTP %d but there is no error "+
+			"in response '%s' (this should not happen!)", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return wrap("while unmarshalling body "+string(body), err)
+	}
+
+	return nil
+}
+
+// ErrNotFound is returned when deleting a resource that does not exist
+var ErrNotFound = errors.New("Resource not found")
+
+// deleteJSON does a signed delete, returning ErrNotFound if the API responds
+// HTTP 404
+func (cli *SignedHTTPClient) deleteJSON(url string) error {
+	resp, err := cli.SignedDelete(url, defaultHeaders)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return ErrNotFound
+	}
+
+	body, val, err := cli.parseResponseJSON(resp)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = cli.isJSONResponseOk(body, val)
+
+	return err
+}
+
+func (cli *SignedHTTPClient) parseResponseJSON(resp *http.Response) (body []byte, val map[string]interface{}, err error) {
 	defer resp.Body.Close()
 
 	body, err = ioutil.ReadAll(resp.Body)
@@ -165,7 +262,7 @@ func (cli *signedHTTPClient) parseResponseJSON(resp *http.Response) (body []byte
 	return
 }
 
-func (cli *signedHTTPClient) isResponseOk(body []byte) (bool, error) {
+func (cli *SignedHTTPClient) isResponseOk(body []byte) (bool, error) {
 	var val map[string]interface{}
 
 	err := json.Unmarshal(body, &val)
@@ -178,7 +275,7 @@ func (cli *signedHTTPClient) isResponseOk(body []byte) (bool, error) {
 	return cli.isJSONResponseOk(body, val)
 }
 
-func (cli *signedHTTPClient) isJSONResponseOk(body []byte, val map[string]interface{}) (bool, error) {
+func (cli *SignedHTTPClient) isJSONResponseOk(body []byte, val map[string]interface{}) (bool, error) {
 	if status, ok := val["status"]; ok { //if status is present
 		if s, ok2 := status.(string); s != "ok" {
 			if !ok2 { //if status couldnt be parsed as string
@@ -0,0 +1,155 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretHeaders are never dumped, bl-msg holds the access key and the
+// signature date
+var secretHeaders = map[string]bool{
+	"Authorization": true,
+	"Bl-Access-Key": true,
+	"Bl-Msg":        true,
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// isSecretField reports if a JSON field holds a password or key, like dbPass
+// or storage-secret-key
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, s := range []string{"pass", "secret", "privatekey", "accountkey"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return name == "key" || name == "keys"
+}
+
+// redactJSON replaces the secret fields of a decoded JSON value
+func redactJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if isSecretField(k) {
+				t[k] = redacted
+			} else {
+				t[k] = redactJSON(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = redactJSON(val)
+		}
+	}
+
+	return v
+}
+
+// dumpBody returns the body with its secrets redacted. Bodies that are not
+// JSON, like install scripts, can hold keys too and are never dumped
+func dumpBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("[%d bytes not shown]", len(body))
+	}
+
+	bs, _ := json.Marshal(redactJSON(v))
+
+	return string(bs)
+}
+
+func dumpHeaders(buff *bytes.Buffer, prefix string, headers http.Header) {
+	names := make([]string, 0, len(headers))
+
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.Join(headers[name], ", ")
+
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			value = redacted
+		}
+
+		fmt.Fprintf(buff, "%s %s: %s\n", prefix, name, value)
+	}
+}
+
+// readBody reads the whole body, leaving a copy in place to be read again
+func readBody(body *io.ReadCloser) []byte {
+	if *body == nil {
+		return nil
+	}
+
+	bs, _ := ioutil.ReadAll(*body)
+	(*body).Close()
+	*body = ioutil.NopCloser(bytes.NewReader(bs))
+
+	return bs
+}
+
+func dumpRequest(req *http.Request) string {
+	var buff bytes.Buffer
+
+	fmt.Fprintf(&buff, "> %s %s\n", req.Method, req.URL)
+	dumpHeaders(&buff, ">", req.Header)
+
+	if body := dumpBody(readBody(&req.Body)); body != "" {
+		fmt.Fprintf(&buff, ">\n> %s\n", body)
+	}
+
+	return buff.String()
+}
+
+func dumpResponse(resp *http.Response) string {
+	var buff bytes.Buffer
+
+	fmt.Fprintf(&buff, "< %s %s\n", resp.Proto, resp.Status)
+	dumpHeaders(&buff, "<", resp.Header)
+
+	if body := dumpBody(readBody(&resp.Body)); body != "" {
+		fmt.Fprintf(&buff, "<\n< %s\n", body)
+	}
+
+	return buff.String()
+}
@@ -0,0 +1,200 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BackupJob ties a server to the storage, schedule and retention policy its
+// backups use
+type BackupJob struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	ServerID    int    `json:"serverId"`
+	StorageID   int    `json:"storageId"`
+	ScheduleID  int    `json:"scheduleId"`
+	RetentionID int    `json:"retentionId"`
+	Enabled     bool   `json:"enabled"`
+
+	BackupType BackupType `json:"backupType"`
+
+	// CompressionLevel goes from 1 (fastest) to 9 (smallest), zero uses the
+	// agent default
+	CompressionLevel int `json:"compressionLevel,omitempty"`
+
+	// Only one of IncludeDatabases and ExcludeDatabases can be set, if none is
+	// every database is backed up
+	IncludeDatabases []string `json:"includeDatabases,omitempty"`
+	ExcludeDatabases []string `json:"excludeDatabases,omitempty"`
+}
+
+type BackupType int
+
+const (
+	BACKUP_FULL        BackupType = 1
+	BACKUP_INCREMENTAL BackupType = 2
+)
+
+func (t BackupType) String() string {
+	switch t {
+	case BACKUP_FULL:
+		return "Full"
+
+	case BACKUP_INCREMENTAL:
+		return "Incremental"
+	}
+
+	return "Unknown"
+}
+
+func ParseBackupType(s string) (BackupType, error) {
+	switch strings.TrimSpace(strings.ToLower(s)) {
+	case "full":
+		return BACKUP_FULL, nil
+
+	case "incremental", "incr":
+		return BACKUP_INCREMENTAL, nil
+	}
+
+	return 0, fmt.Errorf("Backup type %s not recognized", s)
+}
+
+// Validate checks the job before sending it to the API
+func (j BackupJob) Validate() error {
+	if strings.TrimSpace(j.Name) == "" {
+		return fmt.Errorf("Job name cannot be empty")
+	}
+
+	ids := []struct {
+		name string
+		id   int
+	}{{"server", j.ServerID}, {"storage", j.StorageID}, {"schedule", j.ScheduleID}, {"retention", j.RetentionID}}
+
+	for _, ref := range ids {
+		if ref.id <= 0 {
+			return fmt.Errorf("Invalid %s ID %d for job", ref.name, ref.id)
+		}
+	}
+
+	if j.BackupType != BACKUP_FULL && j.BackupType != BACKUP_INCREMENTAL {
+		return fmt.Errorf("Backup type %d not recognized", int(j.BackupType))
+	}
+
+	if j.CompressionLevel < 0 || j.CompressionLevel > 9 {
+		return fmt.Errorf("Compression level %d must be between 1 and 9, or 0 for the default", j.CompressionLevel)
+	}
+
+	if len(j.IncludeDatabases) > 0 && len(j.ExcludeDatabases) > 0 {
+		return fmt.Errorf("Job can either include or exclude databases, not both")
+	}
+
+	for _, db := range append(j.IncludeDatabases, j.ExcludeDatabases...) {
+		if strings.TrimSpace(db) == "" {
+			return fmt.Errorf("Database names cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+func (j BackupJob) String() string {
+	compression := "default"
+
+	if j.CompressionLevel > 0 {
+		compression = strconv.Itoa(j.CompressionLevel)
+	}
+
+	databases := "all"
+
+	if len(j.IncludeDatabases) > 0 {
+		databases = "only " + strings.Join(j.IncludeDatabases, ", ")
+	} else if len(j.ExcludeDatabases) > 0 {
+		databases = "all but " + strings.Join(j.ExcludeDatabases, ", ")
+	}
+
+	return fmt.Sprintf("ID: %d\nName: %s\nEnabled: %t\nServer ID: %d\nStorage ID: %d\n"+
+		"Schedule ID: %d\nRetention ID: %d\nBackup Type: %s\nCompression Level: %s\nDatabases: %s",
+		j.ID, j.Name, j.Enabled, j.ServerID, j.StorageID, j.ScheduleID, j.RetentionID,
+		j.BackupType, compression, databases)
+}
+
+func (j BackupJob) JSONString() string {
+	bs, _ := json.Marshal(j)
+
+	return string(bs)
+}
+
+func (c *Client) GetJob(id int) (job BackupJob, err error) {
+	err = c.httpClient.getJSON(c.host+"/jobs/"+strconv.Itoa(id), &job)
+
+	return
+}
+
+func (c *Client) ListJobs() (jobs []BackupJob, err error) {
+	err = c.httpClient.getJSON(c.host+"/jobs", &jobs)
+
+	return
+}
+
+func (c *Client) CreateJob(job BackupJob) (BackupJob, error) {
+	job.ID = 0
+
+	if err := job.Validate(); err != nil {
+		return job, err
+	}
+
+	val, err := c.httpClient.postJSON(c.host+"/jobs", job)
+
+	if err != nil {
+		return job, wrap("while doing client post", err)
+	}
+
+	if id, ok := val["id"]; ok {
+		if intID, ok2 := id.(float64); ok2 { //json marshalling converts ints to floats
+			job.ID = int(intID)
+		}
+	}
+
+	if job.ID <= 0 {
+		err = fmt.Errorf("Missing ID from job response %v", val)
+	}
+
+	return job, err
+}
+
+func (c *Client) UpdateJob(j BackupJob) error {
+	if j.ID <= 0 {
+		return fmt.Errorf("Invalid ID %d for job", j.ID)
+	}
+
+	if err := j.Validate(); err != nil {
+		return err
+	}
+
+	_, err := c.httpClient.postJSON(c.host+"/jobs/"+strconv.Itoa(j.ID), j)
+
+	if err != nil {
+		return wrap("while doing client post", err)
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteJob(id int) error {
+	return c.httpClient.deleteJSON(c.host + "/jobs/" + strconv.Itoa(id))
+}
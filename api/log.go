@@ -0,0 +1,109 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	LOG_DEBUG Level = 1
+	LOG_INFO  Level = 2
+	LOG_WARN  Level = 3
+	LOG_ERROR Level = 4
+)
+
+func (l Level) String() string {
+	switch l {
+	case LOG_DEBUG:
+		return "DEBUG"
+	case LOG_INFO:
+		return "INFO"
+	case LOG_WARN:
+		return "WARN"
+	case LOG_ERROR:
+		return "ERROR"
+	}
+
+	return "UNKNOWN"
+}
+
+// Logger receives the log messages of the API client, with pairs of keys and
+// values, for ex: Log(LOG_INFO, "request sent", "method", "GET", "status", 200).
+// It must be safe for concurrent use
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(level Level, msg string, keyvals ...interface{}) {}
+
+type textLogger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+// NewTextLogger returns a Logger that writes the messages of the given level
+// or above to out, one per line as "time LEVEL msg key=value...". Values with
+// several lines, like HTTP dumps, are written after the line
+func NewTextLogger(out io.Writer, level Level) Logger {
+	return &textLogger{out: out, level: level}
+}
+
+func (l *textLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level < l.level {
+		return
+	}
+
+	var line, blocks strings.Builder
+
+	fmt.Fprintf(&line, "%s %-5s %s", time.Now().Format(time.RFC3339), level, msg)
+
+	for i := 0; i < len(keyvals); i += 2 {
+		var value interface{} = "(missing)"
+
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+
+		s := fmt.Sprint(value)
+
+		if strings.Contains(s, "\n") {
+			blocks.WriteString(strings.TrimRight(s, "\n"))
+			blocks.WriteString("\n")
+			continue
+		}
+
+		if strings.ContainsAny(s, " \t\"") {
+			s = fmt.Sprintf("%q", s)
+		}
+
+		fmt.Fprintf(&line, " %v=%s", keyvals[i], s)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fmt.Fprintln(l.out, line.String())
+	io.WriteString(l.out, blocks.String())
+}
@@ -24,48 +24,127 @@ import (
 type Retention struct {
 	ID            int           `json:"id"`
 	Name          string        `json:"name"`
-	RetentionType retentionType `json:"retentionType"`
+	RetentionType RetentionType `json:"retentionType"`
 	Count         int           `json:"count"`
+
+	// Grandfather-father-son keep counts, only used by RETENTION_GFS
+	KeepDaily   int `json:"keepDaily,omitempty"`
+	KeepWeekly  int `json:"keepWeekly,omitempty"`
+	KeepMonthly int `json:"keepMonthly,omitempty"`
+	KeepYearly  int `json:"keepYearly,omitempty"`
+
+	// Optional constraints applied on top of any retention type. MinKeep and
+	// KeepLastFull win over MaxTotalSize, zero means no constraint
+	MaxTotalSize int64 `json:"maxTotalSize,omitempty"`
+	MinKeep      int   `json:"minKeep,omitempty"`
+	KeepLastFull int   `json:"keepLastFull,omitempty"`
 }
 
-type retentionType int
+type RetentionType int
 
 const (
-	RETENTION_BY_DAYS  retentionType = 1
-	RETENTION_BY_COUNT retentionType = 2
+	RETENTION_BY_DAYS  RetentionType = 1
+	RETENTION_BY_COUNT RetentionType = 2
+	RETENTION_GFS      RetentionType = 3
 )
 
-func (d retentionType) MarshalJSON() ([]byte, error) {
+func (d RetentionType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.String())
 }
 
-func (d retentionType) String() string {
+func (d RetentionType) String() string {
 	switch d {
 	case RETENTION_BY_DAYS:
 		return "By Days"
 
 	case RETENTION_BY_COUNT:
 		return "By Count"
+
+	case RETENTION_GFS:
+		return "Grandfather-Father-Son"
 	}
 
 	return "Unknown"
 }
 
-func ParseRetentionType(s string) (retentionType, error) {
+func ParseRetentionType(s string) (RetentionType, error) {
 	switch strings.TrimSpace(strings.ToLower(s)) {
 	case "bydays":
 		return RETENTION_BY_DAYS, nil
 
 	case "bycount":
 		return RETENTION_BY_COUNT, nil
+
+	case "gfs":
+		return RETENTION_GFS, nil
 	}
 
 	return 0, fmt.Errorf("Retention type %s not recognized", s)
 }
 
+// Validate checks the retention name and the counts its type uses
+func (r Retention) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("Retention name cannot be empty")
+	}
+
+	switch r.RetentionType {
+	case RETENTION_BY_DAYS, RETENTION_BY_COUNT:
+		if r.Count <= 0 {
+			return fmt.Errorf("Retention count cannot be <= 0")
+		}
+
+	case RETENTION_GFS:
+		if r.KeepDaily < 0 || r.KeepWeekly < 0 || r.KeepMonthly < 0 || r.KeepYearly < 0 {
+			return fmt.Errorf("GFS retention keep counts cannot be negative")
+		}
+
+		if r.KeepDaily+r.KeepWeekly+r.KeepMonthly+r.KeepYearly == 0 {
+			return fmt.Errorf("GFS retention must keep at least one daily, weekly, monthly or yearly backup")
+		}
+
+	default:
+		return fmt.Errorf("Retention type %d not recognized", r.RetentionType)
+	}
+
+	if r.MaxTotalSize < 0 {
+		return fmt.Errorf("Retention max total size cannot be negative")
+	}
+
+	if r.MinKeep < 0 {
+		return fmt.Errorf("Retention min keep cannot be negative")
+	}
+
+	if r.KeepLastFull < 0 {
+		return fmt.Errorf("Retention keep last full cannot be negative")
+	}
+
+	return nil
+}
+
 func (r Retention) String() string {
-	return fmt.Sprintf("ID: %d\nName: %s\nRetention Type: %s\nCount: %d",
-		r.ID, r.Name, r.RetentionType, r.Count)
+	s := fmt.Sprintf("ID: %d\nName: %s\nRetention Type: %s", r.ID, r.Name, r.RetentionType)
+
+	if r.RetentionType != RETENTION_GFS {
+		s += fmt.Sprintf("\nCount: %d", r.Count)
+	} else {
+		s += fmt.Sprintf("\nKeep: %d daily, %d weekly, %d monthly, %d yearly",
+			r.KeepDaily, r.KeepWeekly, r.KeepMonthly, r.KeepYearly)
+	}
+
+	if r.MaxTotalSize > 0 {
+		s += fmt.Sprintf("\nMax Total Size: %d bytes", r.MaxTotalSize)
+	}
+
+	if r.MinKeep > 0 {
+		s += fmt.Sprintf("\nMin Keep: %d", r.MinKeep)
+	}
+
+	if r.KeepLastFull > 0 {
+		s += fmt.Sprintf("\nKeep Last Full: %d", r.KeepLastFull)
+	}
+
+	return s
 }
 
 func (r Retention) JSONString() string {
@@ -108,39 +187,20 @@ func (c *Client) GetRetention(id int) (retention Retention, err error) {
 }
 
 func (c *Client) DeleteRetention(id int) error {
-	resp, err := c.httpClient.SignedDelete(c.host+"/retentions/"+strconv.Itoa(id), defaultHeaders)
-
-	if err != nil {
-		return err
-	}
-
-	body, val, err := c.httpClient.parseResponseJSON(resp)
-
-	if err != nil {
-		return err
-	}
-
-	_, err = c.httpClient.isJSONResponseOk(body, val)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return c.httpClient.deleteJSON(c.host + "/retentions/" + strconv.Itoa(id))
 }
 
-func (c *Client) CreateRetention(name string, retentionType retentionType,
-	count int) (retention Retention, err error) {
+func (c *Client) CreateRetention(retention Retention) (Retention, error) {
+	retention.ID = 0
 
-	retention = Retention{
-		0, name, retentionType, count,
+	if err := retention.Validate(); err != nil {
+		return retention, err
 	}
 
 	val, err := c.httpClient.postJSON(c.host+"/retentions", retention)
 
 	if err != nil {
-		err = wrap("while doing client post", err)
-		return
+		return retention, wrap("while doing client post", err)
 	}
 
 	if id, ok := val["id"]; ok {
@@ -153,7 +213,7 @@ func (c *Client) CreateRetention(name string, retentionType retentionType,
 		err = fmt.Errorf("Missing ID from retention response %v", val)
 	}
 
-	return
+	return retention, err
 }
 
 func (c *Client) UpdateRetention(r Retention) error {
@@ -161,12 +221,8 @@ func (c *Client) UpdateRetention(r Retention) error {
 		return fmt.Errorf("Invalid ID %d for retention", r.ID)
 	}
 
-	if r.Name == "" {
-		return fmt.Errorf("Retention name cannot be empty")
-	}
-
-	if r.Count <= 0 {
-		return fmt.Errorf("Retention count cannot be <= 0")
+	if err := r.Validate(); err != nil {
+		return err
 	}
 
 	_, err := c.httpClient.postJSON(c.host+"/retentions/"+strconv.Itoa(r.ID), r)
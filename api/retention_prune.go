@@ -0,0 +1,182 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Prune splits the backups of a single job into the ones the retention keeps
+// and the ones it deletes as of now. Both are returned oldest first.
+//
+// Incremental backups need the backups they were taken on top of, so keeping
+// one also keeps every backup back to its previous full backup.
+//
+// The constraints are applied after the retention type: the oldest backups
+// are deleted until the kept ones fit in MaxTotalSize, and then the newest
+// MinKeep backups and the newest KeepLastFull full backups are kept anyway.
+// Backups with an active hold are never deleted.
+//
+// Failed or partial backups can't be restored, so they neither count for the
+// retention nor anchor a chain, and are left out of both lists
+func (r Retention) Prune(backups []Backup, now time.Time, holds ...BackupHold) (keep, prune []Backup) {
+	var sorted []Backup
+
+	for _, b := range backups {
+		if b.Succeeded() {
+			sorted = append(sorted, b)
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartedAt.Before(sorted[j].StartedAt.Time) })
+
+	kept := make([]bool, len(sorted))
+
+	switch r.RetentionType {
+	case RETENTION_BY_COUNT:
+		for i := len(sorted) - 1; i >= 0 && i >= len(sorted)-r.Count; i-- {
+			kept[i] = true
+		}
+
+	case RETENTION_BY_DAYS:
+		since := now.AddDate(0, 0, -r.Count)
+
+		for i, b := range sorted {
+			kept[i] = !b.StartedAt.Before(since)
+		}
+
+	case RETENTION_GFS:
+		loc := now.Location()
+
+		keepNewestPerPeriod(sorted, kept, r.KeepDaily, func(t time.Time) string {
+			return t.In(loc).Format("2006-01-02")
+		})
+
+		keepNewestPerPeriod(sorted, kept, r.KeepWeekly, func(t time.Time) string {
+			year, week := t.In(loc).ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		})
+
+		keepNewestPerPeriod(sorted, kept, r.KeepMonthly, func(t time.Time) string {
+			return t.In(loc).Format("2006-01")
+		})
+
+		keepNewestPerPeriod(sorted, kept, r.KeepYearly, func(t time.Time) string {
+			return t.In(loc).Format("2006")
+		})
+
+	default:
+		// unknown policies never delete anything
+		for i := range kept {
+			kept[i] = true
+		}
+	}
+
+	keepChains(sorted, kept)
+
+	if r.MaxTotalSize > 0 {
+		var total int64
+
+		for i, b := range sorted {
+			if kept[i] {
+				total += b.Size
+			}
+		}
+
+		// drop whole chains from the oldest, so no incremental loses its base
+		for i := 0; i < len(sorted) && total > r.MaxTotalSize; i++ {
+			if !kept[i] {
+				continue
+			}
+
+			kept[i] = false
+			total -= sorted[i].Size
+
+			for i+1 < len(sorted) && kept[i+1] && !sorted[i+1].IsFull() {
+				i++
+				kept[i] = false
+				total -= sorted[i].Size
+			}
+		}
+	}
+
+	for i, n := len(sorted)-1, r.MinKeep; i >= 0 && n > 0; i, n = i-1, n-1 {
+		kept[i] = true
+	}
+
+	for i, n := len(sorted)-1, r.KeepLastFull; i >= 0 && n > 0; i-- {
+		if sorted[i].IsFull() {
+			kept[i] = true
+			n--
+		}
+	}
+
+	held := heldBackups(holds, now)
+
+	for i, b := range sorted {
+		if held[b.ID] {
+			kept[i] = true
+		}
+	}
+
+	keepChains(sorted, kept)
+
+	for i, b := range sorted {
+		if kept[i] {
+			keep = append(keep, b)
+		} else {
+			prune = append(prune, b)
+		}
+	}
+
+	return
+}
+
+// keepChains marks the backups every kept incremental backup depends on, back
+// to its previous full backup
+func keepChains(backups []Backup, kept []bool) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !kept[i] || backups[i].IsFull() {
+			continue
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			kept[j] = true
+
+			if backups[j].IsFull() {
+				break
+			}
+		}
+	}
+}
+
+// keepNewestPerPeriod marks the newest backup of each of the last count
+// periods that have backups. backups must be sorted oldest first
+func keepNewestPerPeriod(backups []Backup, kept []bool, count int, period func(time.Time) string) {
+	last := ""
+
+	for i := len(backups) - 1; i >= 0 && count > 0; i-- {
+		p := period(backups[i].StartedAt.Time)
+
+		if p == last {
+			continue
+		}
+
+		kept[i] = true
+		last = p
+		count--
+	}
+}
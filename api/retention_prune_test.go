@@ -0,0 +1,226 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetentionPrune(t *testing.T) {
+	now := time.Date(2018, time.June, 30, 12, 0, 0, 0, time.UTC)
+
+	// backup returns a backup of size 10 started the given hours before now
+	backup := func(id, hoursAgo int, backupType, status string) Backup {
+		return Backup{ID: id, BackupType: backupType, Status: status, Size: 10,
+			StartedAt: Timestamp{now.Add(-time.Duration(hoursAgo) * time.Hour)}}
+	}
+
+	fulls := []Backup{
+		backup(1, 96, "full", "success"),
+		backup(2, 72, "full", "success"),
+		backup(3, 48, "full", "success"),
+		backup(4, 24, "full", "success"),
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		backups   []Backup
+		holds     []BackupHold
+		keep      []int
+		prune     []int
+	}{
+		{
+			name:      "by count",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 2},
+			backups:   fulls,
+			keep:      []int{3, 4},
+			prune:     []int{1, 2},
+		},
+		{
+			name:      "by count more than backups",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 10},
+			backups:   fulls,
+			keep:      []int{1, 2, 3, 4},
+		},
+		{
+			name:      "unsorted input",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+			backups:   []Backup{fulls[3], fulls[0], fulls[2], fulls[1]},
+			keep:      []int{4},
+			prune:     []int{1, 2, 3},
+		},
+		{
+			name:      "by days",
+			retention: Retention{RetentionType: RETENTION_BY_DAYS, Count: 2},
+			backups:   fulls,
+			keep:      []int{3, 4},
+			prune:     []int{1, 2},
+		},
+		{
+			name:      "failed backups do not count",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 2},
+			backups: []Backup{
+				backup(1, 72, "full", "success"),
+				backup(2, 48, "full", "success"),
+				backup(3, 24, "full", "failed"),
+				backup(4, 12, "full", "partial"),
+			},
+			keep:  []int{1, 2},
+			prune: nil,
+		},
+		{
+			name:      "missing status is successful",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+			backups:   []Backup{backup(1, 48, "full", ""), backup(2, 24, "", "")},
+			keep:      []int{2},
+			prune:     []int{1},
+		},
+		{
+			name:      "incrementals keep their chain",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+			backups: []Backup{
+				backup(1, 96, "full", "success"),
+				backup(2, 72, "full", "success"),
+				backup(3, 48, "incremental", "success"),
+				backup(4, 24, "incremental", "success"),
+			},
+			keep:  []int{2, 3, 4},
+			prune: []int{1},
+		},
+		{
+			name:      "failed full does not anchor a chain",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+			backups: []Backup{
+				backup(1, 96, "full", "success"),
+				backup(2, 72, "full", "failed"),
+				backup(3, 48, "incremental", "success"),
+			},
+			keep: []int{1, 3},
+		},
+		{
+			name:      "gfs",
+			retention: Retention{RetentionType: RETENTION_GFS, KeepDaily: 2, KeepWeekly: 1},
+			backups: []Backup{
+				backup(1, 24*9, "full", "success"),
+				backup(2, 50, "full", "success"),
+				backup(3, 26, "full", "success"),
+				backup(4, 24, "full", "success"),
+				backup(5, 2, "full", "success"),
+			},
+			keep:  []int{4, 5},
+			prune: []int{1, 2, 3},
+		},
+		{
+			name:      "gfs weekly and monthly",
+			retention: Retention{RetentionType: RETENTION_GFS, KeepWeekly: 2, KeepMonthly: 2},
+			backups: []Backup{
+				backup(1, 24*40, "full", "success"),
+				backup(2, 24*14, "full", "success"),
+				backup(3, 24*8, "full", "success"),
+				backup(4, 24, "full", "success"),
+			},
+			keep:  []int{1, 3, 4},
+			prune: []int{2},
+		},
+		{
+			name:      "max total size",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 4, MaxTotalSize: 25},
+			backups:   fulls,
+			keep:      []int{3, 4},
+			prune:     []int{1, 2},
+		},
+		{
+			name:      "max total size drops whole chains",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 4, MaxTotalSize: 25},
+			backups: []Backup{
+				backup(1, 96, "full", "success"),
+				backup(2, 72, "incremental", "success"),
+				backup(3, 48, "full", "success"),
+				backup(4, 24, "incremental", "success"),
+			},
+			keep:  []int{3, 4},
+			prune: []int{1, 2},
+		},
+		{
+			name:      "min keep",
+			retention: Retention{RetentionType: RETENTION_BY_DAYS, Count: 0, MinKeep: 2},
+			backups:   fulls,
+			keep:      []int{3, 4},
+			prune:     []int{1, 2},
+		},
+		{
+			name:      "keep last full",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1, KeepLastFull: 2},
+			backups: []Backup{
+				backup(1, 96, "full", "success"),
+				backup(2, 72, "full", "success"),
+				backup(3, 48, "full", "success"),
+				backup(4, 24, "incremental", "success"),
+			},
+			keep:  []int{2, 3, 4},
+			prune: []int{1},
+		},
+		{
+			name:      "active hold",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+			backups:   fulls,
+			holds:     []BackupHold{{BackupID: 1}, {BackupID: 2, Until: Timestamp{now.Add(time.Hour)}}},
+			keep:      []int{1, 2, 4},
+			prune:     []int{3},
+		},
+		{
+			name:      "expired hold",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+			backups:   fulls,
+			holds:     []BackupHold{{BackupID: 1, Until: Timestamp{now.Add(-time.Hour)}}},
+			keep:      []int{4},
+			prune:     []int{1, 2, 3},
+		},
+		{
+			name:      "unknown type keeps everything",
+			retention: Retention{RetentionType: 99},
+			backups:   fulls,
+			keep:      []int{1, 2, 3, 4},
+		},
+		{
+			name:      "no backups",
+			retention: Retention{RetentionType: RETENTION_BY_COUNT, Count: 1},
+		},
+	}
+
+	ids := func(backups []Backup) []int {
+		var out []int
+
+		for _, b := range backups {
+			out = append(out, b.ID)
+		}
+
+		return out
+	}
+
+	for _, tt := range tests {
+		keep, prune := tt.retention.Prune(tt.backups, now, tt.holds...)
+
+		if got := ids(keep); !reflect.DeepEqual(got, tt.keep) {
+			t.Errorf("%s: keep = %v, want %v", tt.name, got, tt.keep)
+		}
+
+		if got := ids(prune); !reflect.DeepEqual(got, tt.prune) {
+			t.Errorf("%s: prune = %v, want %v", tt.name, got, tt.prune)
+		}
+	}
+}
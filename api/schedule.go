@@ -19,27 +19,33 @@ import (
 	"io/ioutil"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Schedule struct {
-	ID            int          `json:"id"`
-	Name          string       `json:"name"`
-	ScheduleType  scheduleType `json:"scheduleType"`
-	ScheduleHours string       `json:"scheduleHours"`
-	ScheduleDays  string       `json:"scheduleDays"`
+	ID             int          `json:"id"`
+	Name           string       `json:"name"`
+	ScheduleType   ScheduleType `json:"scheduleType"`
+	ScheduleHours  string       `json:"scheduleHours"`
+	ScheduleDays   string       `json:"scheduleDays"`
+	CronExpression string       `json:"cronExpression,omitempty"`
+
+	// Timezone is the IANA time zone the schedule times are in, UTC if empty
+	Timezone string `json:"timezone,omitempty"`
 }
 
-type scheduleType int
+type ScheduleType int
 
 const (
-	SCHEDULE_ON_DEMAND scheduleType = 1
-	SCHEDULE_HOURLY    scheduleType = 2
-	SCHEDULE_DAILY     scheduleType = 3
-	SCHEDULE_WEEKLY    scheduleType = 4
-	SCHEDULE_MONTHLY   scheduleType = 5
+	SCHEDULE_ON_DEMAND ScheduleType = 1
+	SCHEDULE_HOURLY    ScheduleType = 2
+	SCHEDULE_DAILY     ScheduleType = 3
+	SCHEDULE_WEEKLY    ScheduleType = 4
+	SCHEDULE_MONTHLY   ScheduleType = 5
+	SCHEDULE_CRON      ScheduleType = 6
 )
 
-func (d scheduleType) String() string {
+func (d ScheduleType) String() string {
 	switch d {
 	case SCHEDULE_ON_DEMAND:
 		return "On Demand"
@@ -51,12 +57,14 @@ func (d scheduleType) String() string {
 		return "Weekly"
 	case SCHEDULE_MONTHLY:
 		return "Monthly"
+	case SCHEDULE_CRON:
+		return "Cron"
 	}
 
 	return "Unknown"
 }
 
-func ParseScheduleType(s string) (scheduleType, error) {
+func ParseScheduleType(s string) (ScheduleType, error) {
 	switch strings.TrimSpace(strings.ToLower(s)) {
 	case "ondemand":
 		return SCHEDULE_ON_DEMAND, nil
@@ -68,28 +76,126 @@ func ParseScheduleType(s string) (scheduleType, error) {
 		return SCHEDULE_WEEKLY, nil
 	case "monthly":
 		return SCHEDULE_MONTHLY, nil
+	case "cron":
+		return SCHEDULE_CRON, nil
 	}
 
 	return 0, fmt.Errorf("Schedule type %s not recognized", s)
 }
 
 func (s Schedule) String() string {
-	daysHours := ""
+	lines := []string{fmt.Sprintf("ID: %d\nName: %s\nSchedule Type: %s", s.ID, s.Name, s.ScheduleType)}
 
 	if s.ScheduleDays != "" {
-		daysHours += "Days: " + s.ScheduleDays
+		lines = append(lines, "Days: "+s.ScheduleDays)
+	}
+
+	if s.ScheduleHours != "" {
+		lines = append(lines, "Hours: "+s.ScheduleHours)
+	}
+
+	if s.CronExpression != "" {
+		lines = append(lines, "Cron: "+s.CronExpression)
+	}
+
+	if s.Timezone != "" {
+		lines = append(lines, "Timezone: "+s.Timezone)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Location returns the schedule time zone, UTC if not set
+func (s Schedule) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unknown timezone '%s', expected an IANA name like 'America/New_York'", s.Timezone)
+	}
+
+	return loc, nil
+}
+
+// Validate checks the schedule before sending it to the API
+func (s Schedule) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("Schedule name cannot be empty")
+	}
+
+	if _, err := s.Location(); err != nil {
+		return err
+	}
+
+	_, err := s.Spec()
+
+	return err
+}
+
+// NextRuns returns up to count runs of the schedule strictly after from,
+// evaluated in the schedule time zone. Runs inside any of the blackouts are
+// skipped
+func (s Schedule) NextRuns(from time.Time, count int, blackouts ...Blackout) ([]time.Time, error) {
+	spec, err := s.Spec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := s.Location()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []time.Time
+	t := from.In(loc)
+
+	// bound the runs skipped by blackouts, in case they cover every run
+	for skipped := 0; len(runs) < count && skipped < 100000; {
+		if t = spec.Next(t); t.IsZero() {
+			break
+		}
 
-		if s.ScheduleHours != "" {
-			daysHours += "\n"
+		if _, ok := inBlackout(t, blackouts); ok {
+			skipped++
+			continue
 		}
+
+		runs = append(runs, t)
 	}
 
-	if s.ScheduleHours != "" {
-		daysHours += "Hours: " + s.ScheduleHours
+	return runs, nil
+}
+
+// RunsBetween returns the runs of the schedule in the [from, to) interval,
+// skipping the runs inside any of the blackouts
+func (s Schedule) RunsBetween(from, to time.Time, blackouts ...Blackout) ([]time.Time, error) {
+	spec, err := s.Spec()
+
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := s.Location()
+
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Sprintf("ID: %d\nName: %s\nSchedule Type: %s\n%s",
-		s.ID, s.Name, s.ScheduleType, daysHours)
+	var runs []time.Time
+
+	// Next is strictly after, so start a nanosecond early to include from
+	for t := spec.Next(from.Add(-time.Nanosecond).In(loc)); !t.IsZero() && t.Before(to); t = spec.Next(t) {
+		if _, ok := inBlackout(t, blackouts); !ok {
+			runs = append(runs, t)
+		}
+	}
+
+	return runs, nil
 }
 
 func (s Schedule) JSONString() string {
@@ -131,33 +237,25 @@ func (c *Client) GetSchedule(id int) (schedule Schedule, err error) {
 	return
 }
 
-func (c *Client) DeleteSchedule(id int) error {
-	resp, err := c.httpClient.SignedDelete(c.host+"/schedules/"+strconv.Itoa(id), defaultHeaders)
-
-	if err != nil {
-		return err
-	}
-
-	body, val, err := c.httpClient.parseResponseJSON(resp)
-
-	if err != nil {
-		return err
-	}
-
-	_, err = c.httpClient.isJSONResponseOk(body, val)
+func (c *Client) ListSchedules() (schedules []Schedule, err error) {
+	err = c.httpClient.getJSON(c.host+"/schedules", &schedules)
 
-	if err != nil {
-		return err
-	}
+	return
+}
 
-	return nil
+func (c *Client) DeleteSchedule(id int) error {
+	return c.httpClient.deleteJSON(c.host + "/schedules/" + strconv.Itoa(id))
 }
 
-func (c *Client) CreateSchedule(name string, scheduleType scheduleType, hours string,
-	days string) (schedule Schedule, err error) {
+func (c *Client) CreateSchedule(name string, scheduleType ScheduleType, hours string,
+	days string, cron string, timezone string) (schedule Schedule, err error) {
 
 	schedule = Schedule{
-		0, name, scheduleType, hours, days,
+		0, name, scheduleType, hours, days, cron, timezone,
+	}
+
+	if err = schedule.Validate(); err != nil {
+		return
 	}
 
 	val, err := c.httpClient.postJSON(c.host+"/schedules", schedule)
@@ -185,8 +283,8 @@ func (c *Client) UpdateSchedule(s Schedule) error {
 		return fmt.Errorf("Invalid ID %d for schedule", s.ID)
 	}
 
-	if s.Name == "" {
-		return fmt.Errorf("Schedule name cannot be empty")
+	if err := s.Validate(); err != nil {
+		return err
 	}
 
 	_, err := c.httpClient.postJSON(c.host+"/schedules/"+strconv.Itoa(s.ID), s)
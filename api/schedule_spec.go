@@ -0,0 +1,381 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LAST_DAY_OF_MONTH is the month day used for 'L' or 'last' in monthly schedules
+const LAST_DAY_OF_MONTH = -1
+
+// TimeOfDay is an HH:MM time a schedule runs at
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+// ScheduleSpec is the parsed form of the schedule hours and days, which the
+// API receives as free text
+type ScheduleSpec struct {
+	Type ScheduleType
+
+	// IntervalHours is every how many hours an hourly schedule runs
+	IntervalHours int
+
+	// Times is when daily, weekly and monthly schedules run, sorted
+	Times []TimeOfDay
+
+	// Weekdays is which days of the week a weekly schedule runs, sorted
+	Weekdays []time.Weekday
+
+	// MonthDays is which days of the month a monthly schedule runs, sorted,
+	// with LAST_DAY_OF_MONTH first if present. Days past the end of a month
+	// run on its last day, so 31 runs on the 30th in April
+	MonthDays []int
+
+	Cron *CronSchedule
+}
+
+// Spec parses and validates the schedule hours and days for its type
+func (s Schedule) Spec() (ScheduleSpec, error) {
+	spec := ScheduleSpec{Type: s.ScheduleType}
+	hours := strings.TrimSpace(s.ScheduleHours)
+	days := strings.TrimSpace(s.ScheduleDays)
+
+	var err error
+
+	if s.ScheduleType != SCHEDULE_CRON && s.CronExpression != "" {
+		return spec, fmt.Errorf("Cron expression only applies to cron schedules")
+	}
+
+	switch s.ScheduleType {
+	case SCHEDULE_ON_DEMAND:
+		if hours != "" || days != "" {
+			return spec, fmt.Errorf("On demand schedules do not accept hours nor days")
+		}
+
+	case SCHEDULE_HOURLY:
+		if days != "" {
+			return spec, fmt.Errorf("Hourly schedules do not accept days, got '%s'", days)
+		}
+
+		spec.IntervalHours, err = ParseHourlyInterval(hours)
+
+	case SCHEDULE_DAILY:
+		if days != "" {
+			return spec, fmt.Errorf("Daily schedules do not accept days, got '%s'", days)
+		}
+
+		spec.Times, err = ParseTimesOfDay(hours)
+
+	case SCHEDULE_WEEKLY:
+		if spec.Times, err = ParseTimesOfDay(hours); err != nil {
+			return spec, err
+		}
+
+		spec.Weekdays, err = ParseWeekdays(days)
+
+	case SCHEDULE_MONTHLY:
+		if spec.Times, err = ParseTimesOfDay(hours); err != nil {
+			return spec, err
+		}
+
+		spec.MonthDays, err = ParseMonthDays(days)
+
+	case SCHEDULE_CRON:
+		if hours != "" || days != "" {
+			return spec, fmt.Errorf("Cron schedules do not accept hours nor days, use the cron expression")
+		}
+
+		if strings.TrimSpace(s.CronExpression) == "" {
+			return spec, fmt.Errorf("Cron expression cannot be empty for cron schedules")
+		}
+
+		spec.Cron, err = ParseCron(s.CronExpression)
+
+	default:
+		return spec, fmt.Errorf("Schedule type %d not recognized", int(s.ScheduleType))
+	}
+
+	return spec, err
+}
+
+// ParseHourlyInterval parses every how many hours an hourly schedule runs, 1 to 23
+func ParseHourlyInterval(s string) (int, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return 0, fmt.Errorf("Hourly schedules need the hours interval")
+	}
+
+	if !isDigits(s) {
+		return 0, fmt.Errorf("Invalid hours interval '%s', must be a number of hours", s)
+	}
+
+	n, err := strconv.Atoi(s)
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid hours interval '%s', must be a number of hours", s)
+	}
+
+	if n < 1 || n > 23 {
+		return 0, fmt.Errorf("Invalid hours interval %d, must be between 1 and 23 (use a daily schedule instead)", n)
+	}
+
+	return n, nil
+}
+
+// ParseTimesOfDay parses a comma separated list of HH:MM times
+func ParseTimesOfDay(s string) ([]TimeOfDay, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("Schedule hours cannot be empty, expected HH:MM times")
+	}
+
+	var times []TimeOfDay
+	seen := map[TimeOfDay]bool{}
+
+	for _, part := range strings.Split(s, ",") {
+		t, err := parseTimeOfDay(strings.TrimSpace(part))
+
+		if err != nil {
+			return nil, wrap("in hours '"+s+"'", err)
+		}
+
+		if seen[t] {
+			return nil, fmt.Errorf("Duplicated time %s in hours '%s'", t, s)
+		}
+
+		seen[t] = true
+		times = append(times, t)
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Hour*60+times[i].Minute < times[j].Hour*60+times[j].Minute
+	})
+
+	return times, nil
+}
+
+func parseTimeOfDay(s string) (TimeOfDay, error) {
+	parts := strings.Split(s, ":")
+
+	if len(parts) != 2 || len(parts[0]) < 1 || len(parts[0]) > 2 || len(parts[1]) != 2 {
+		return TimeOfDay{}, fmt.Errorf("Invalid time '%s', expected HH:MM format", s)
+	}
+
+	// Atoi accepts signs, like +1 or -0
+	if !isDigits(parts[0]) {
+		return TimeOfDay{}, fmt.Errorf("Invalid time '%s', hour is not a number", s)
+	}
+
+	if !isDigits(parts[1]) {
+		return TimeOfDay{}, fmt.Errorf("Invalid time '%s', minute is not a number", s)
+	}
+
+	hour, _ := strconv.Atoi(parts[0])
+	minute, _ := strconv.Atoi(parts[1])
+
+	if hour > 23 {
+		return TimeOfDay{}, fmt.Errorf("Invalid time '%s', hour must be between 00 and 23", s)
+	}
+
+	if minute > 59 {
+		return TimeOfDay{}, fmt.Errorf("Invalid time '%s', minute must be between 00 and 59", s)
+	}
+
+	return TimeOfDay{hour, minute}, nil
+}
+
+// isDigits reports if s only has ASCII digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// ParseWeekdays parses a comma separated list of week days, 0 being Sunday
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	days, err := parseDayList(s, 0, 6, "weekly", "0 being Sunday")
+
+	if err != nil {
+		return nil, err
+	}
+
+	weekdays := make([]time.Weekday, len(days))
+
+	for i, d := range days {
+		weekdays[i] = time.Weekday(d)
+	}
+
+	return weekdays, nil
+}
+
+// ParseMonthDays parses a comma separated list of month days, 1 to 31, or
+// 'L' for the last day of the month
+func ParseMonthDays(s string) ([]int, error) {
+	return parseDayList(s, 1, 31, "monthly", "or 'L' for the last day of the month")
+}
+
+func parseDayList(s string, min, max int, scheduleName, hint string) ([]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("Schedule days cannot be empty for %s schedules", scheduleName)
+	}
+
+	var days []int
+	seen := map[int]bool{}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		var day int
+		var err error
+
+		if scheduleName == "monthly" && (strings.EqualFold(part, "L") || strings.EqualFold(part, "last")) {
+			day = LAST_DAY_OF_MONTH
+		} else if day, err = strconv.Atoi(part); err != nil || !isDigits(part) {
+			return nil, fmt.Errorf("Invalid day '%s' in days '%s' for %s schedule, must be a number "+
+				"between %d and %d (%s)", part, s, scheduleName, min, max, hint)
+		} else if day < min || day > max {
+			return nil, fmt.Errorf("Invalid day %d in days '%s' for %s schedule, must be between "+
+				"%d and %d (%s)", day, s, scheduleName, min, max, hint)
+		}
+
+		if seen[day] {
+			return nil, fmt.Errorf("Duplicated day '%s' in days '%s'", part, s)
+		}
+
+		seen[day] = true
+		days = append(days, day)
+	}
+
+	sort.Ints(days)
+
+	return days, nil
+}
+
+// RunsOnDay reports if a monthly schedule runs on the day of t. Days past the
+// end of the month run on its last day
+func (spec ScheduleSpec) RunsOnDay(t time.Time) bool {
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 12, 0, 0, 0, t.Location()).Day()
+
+	for _, d := range spec.MonthDays {
+		if d == t.Day() || (t.Day() == lastDay && (d == LAST_DAY_OF_MONTH || d > lastDay)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scheduleSearchDays bounds the search for the next run, leap days every 4
+// years being the sparsest schedule possible
+const scheduleSearchDays = 366 * 8
+
+// nextWallClock returns the first time strictly after the given one that
+// falls in a day accepted by matchDay at one of the given times, which must
+// be sorted. Times are evaluated in the wall clock of the location of after:
+// wall clock times skipped by a DST change are not run, and wall clock times
+// repeated by a DST change run only once, at their first occurrence
+func nextWallClock(after time.Time, matchDay func(time.Time) bool, times []TimeOfDay) time.Time {
+	loc := after.Location()
+	year, month, day := after.Date()
+
+	for i := 0; i < scheduleSearchDays && len(times) > 0; i++ {
+		// noon is never affected by DST changes
+		d := time.Date(year, month, day+i, 12, 0, 0, 0, loc)
+
+		if !matchDay(d) {
+			continue
+		}
+
+		for _, tod := range times {
+			if i == 0 && tod.Hour*60+tod.Minute <= after.Hour()*60+after.Minute() {
+				continue
+			}
+
+			t := time.Date(d.Year(), d.Month(), d.Day(), tod.Hour, tod.Minute, 0, 0, loc)
+
+			// the wall clock time does not exist in this day (DST gap)
+			if t.Hour() != tod.Hour || t.Minute() != tod.Minute || t.Day() != d.Day() {
+				continue
+			}
+
+			// first occurrence of a repeated wall clock time
+			if earlier := t.Add(-time.Hour); earlier.Hour() == tod.Hour && earlier.Minute() == tod.Minute {
+				t = earlier
+			}
+
+			if t.After(after) {
+				return t
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// Next returns the first run strictly after the given time, evaluated in its
+// location. Hourly schedules run every IntervalHours starting at 00:00 each
+// day. It returns the zero time for on demand schedules
+func (spec ScheduleSpec) Next(after time.Time) time.Time {
+	anyDay := func(time.Time) bool { return true }
+
+	switch spec.Type {
+	case SCHEDULE_HOURLY:
+		var times []TimeOfDay
+
+		for h := 0; h < 24 && spec.IntervalHours > 0; h += spec.IntervalHours {
+			times = append(times, TimeOfDay{h, 0})
+		}
+
+		return nextWallClock(after, anyDay, times)
+
+	case SCHEDULE_DAILY:
+		return nextWallClock(after, anyDay, spec.Times)
+
+	case SCHEDULE_WEEKLY:
+		return nextWallClock(after, func(t time.Time) bool {
+			for _, d := range spec.Weekdays {
+				if t.Weekday() == d {
+					return true
+				}
+			}
+
+			return false
+		}, spec.Times)
+
+	case SCHEDULE_MONTHLY:
+		return nextWallClock(after, spec.RunsOnDay, spec.Times)
+
+	case SCHEDULE_CRON:
+		if spec.Cron != nil {
+			return spec.Cron.Next(after)
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,312 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHourlyInterval(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr string
+	}{
+		{"1", 1, ""},
+		{"6", 6, ""},
+		{" 12 ", 12, ""},
+		{"23", 23, ""},
+		{"", 0, "need the hours interval"},
+		{"0", 0, "between 1 and 23"},
+		{"24", 0, "between 1 and 23"},
+		{"-2", 0, "must be a number of hours"},
+		{"+6", 0, "must be a number of hours"},
+		{"-0", 0, "must be a number of hours"},
+		{"1.5", 0, "must be a number of hours"},
+		{"02:00", 0, "must be a number of hours"},
+		{"six", 0, "must be a number of hours"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHourlyInterval(tt.in)
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("ParseHourlyInterval(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseHourlyInterval(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimesOfDay(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []TimeOfDay
+		wantErr string
+	}{
+		{"00:00", []TimeOfDay{{0, 0}}, ""},
+		{"02:30", []TimeOfDay{{2, 30}}, ""},
+		{"2:30", []TimeOfDay{{2, 30}}, ""},
+		{"23:59", []TimeOfDay{{23, 59}}, ""},
+		{"18:00,06:00", []TimeOfDay{{6, 0}, {18, 0}}, ""},
+		{" 06:00 , 12:15 ", []TimeOfDay{{6, 0}, {12, 15}}, ""},
+		{"", nil, "cannot be empty"},
+		{"   ", nil, "cannot be empty"},
+		{"25:00", nil, "hour must be between 00 and 23"},
+		{"24:00", nil, "hour must be between 00 and 23"},
+		{"12:60", nil, "minute must be between 00 and 59"},
+		{"12:5", nil, "expected HH:MM format"},
+		{"123:00", nil, "expected HH:MM format"},
+		{"12", nil, "expected HH:MM format"},
+		{"12:00:00", nil, "expected HH:MM format"},
+		{":30", nil, "expected HH:MM format"},
+		{"ab:00", nil, "hour is not a number"},
+		{"12:ab", nil, "minute is not a number"},
+		{"-1:00", nil, "hour is not a number"},
+		{"+1:00", nil, "hour is not a number"},
+		{"-0:00", nil, "hour is not a number"},
+		{"1:+5", nil, "minute is not a number"},
+		{"1:-5", nil, "minute is not a number"},
+		{"06:00,", nil, "expected HH:MM format"},
+		{"06:00,06:00", nil, "Duplicated time 06:00"},
+		{"06:00,6:00", nil, "Duplicated time 06:00"},
+		{"06:00,25:00", nil, "in hours '06:00,25:00'"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimesOfDay(tt.in)
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("ParseTimesOfDay(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTimesOfDay(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []time.Weekday
+		wantErr string
+	}{
+		{"0", []time.Weekday{time.Sunday}, ""},
+		{"6", []time.Weekday{time.Saturday}, ""},
+		{"1,3,5", []time.Weekday{time.Monday, time.Wednesday, time.Friday}, ""},
+		{"5, 1", []time.Weekday{time.Monday, time.Friday}, ""},
+		{"0,1,2,3,4,5,6", []time.Weekday{0, 1, 2, 3, 4, 5, 6}, ""},
+		{"", nil, "cannot be empty for weekly schedules"},
+		{"7", nil, "Invalid day 7 in days '7' for weekly schedule, must be between 0 and 6 (0 being Sunday)"},
+		{"8", nil, "must be between 0 and 6"},
+		{"-1", nil, "Invalid day '-1' in days '-1' for weekly schedule, must be a number between 0 and 6"},
+		{"+3", nil, "Invalid day '+3'"},
+		{"1,-0", nil, "Invalid day '-0'"},
+		{"mon", nil, "must be a number between 0 and 6"},
+		{"1,,2", nil, "Invalid day ''"},
+		{"1-5", nil, "Invalid day '1-5'"},
+		{"L", nil, "Invalid day 'L'"},
+		{"1,1", nil, "Duplicated day '1'"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWeekdays(tt.in)
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("ParseWeekdays(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseWeekdays(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMonthDays(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr string
+	}{
+		{"1", []int{1}, ""},
+		{"31", []int{31}, ""},
+		{"15,1", []int{1, 15}, ""},
+		{"L", []int{LAST_DAY_OF_MONTH}, ""},
+		{"last", []int{LAST_DAY_OF_MONTH}, ""},
+		{"1,L", []int{LAST_DAY_OF_MONTH, 1}, ""},
+		{"", nil, "cannot be empty for monthly schedules"},
+		{"0", nil, "Invalid day 0 in days '0' for monthly schedule, must be between 1 and 31"},
+		{"32", nil, "must be between 1 and 31"},
+		{"first", nil, "must be a number between 1 and 31"},
+		{"+3,-0", nil, "Invalid day '+3'"},
+		{"1,+15", nil, "Invalid day '+15'"},
+		{"1,1", nil, "Duplicated day '1'"},
+		{"L,last", nil, "Duplicated day 'last'"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMonthDays(tt.in)
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("ParseMonthDays(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMonthDays(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		wantErr  string
+	}{
+		{"ondemand", Schedule{ScheduleType: SCHEDULE_ON_DEMAND}, ""},
+		{"ondemand with hours", Schedule{ScheduleType: SCHEDULE_ON_DEMAND, ScheduleHours: "02:00"}, "do not accept hours"},
+		{"ondemand with days", Schedule{ScheduleType: SCHEDULE_ON_DEMAND, ScheduleDays: "1"}, "do not accept hours nor days"},
+		{"hourly", Schedule{ScheduleType: SCHEDULE_HOURLY, ScheduleHours: "4"}, ""},
+		{"hourly with time", Schedule{ScheduleType: SCHEDULE_HOURLY, ScheduleHours: "04:00"}, "must be a number of hours"},
+		{"hourly with days", Schedule{ScheduleType: SCHEDULE_HOURLY, ScheduleHours: "4", ScheduleDays: "1"}, "do not accept days"},
+		{"daily", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "02:00"}, ""},
+		{"daily many times", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "02:00,14:00"}, ""},
+		{"daily without hours", Schedule{ScheduleType: SCHEDULE_DAILY}, "cannot be empty"},
+		{"daily invalid time", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "25:00"}, "hour must be between 00 and 23"},
+		{"daily with days", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "02:00", ScheduleDays: "1"}, "do not accept days"},
+		{"weekly", Schedule{ScheduleType: SCHEDULE_WEEKLY, ScheduleHours: "02:00", ScheduleDays: "0,3"}, ""},
+		{"weekly invalid day", Schedule{ScheduleType: SCHEDULE_WEEKLY, ScheduleHours: "02:00", ScheduleDays: "8"}, "must be between 0 and 6"},
+		{"weekly without days", Schedule{ScheduleType: SCHEDULE_WEEKLY, ScheduleHours: "02:00"}, "cannot be empty for weekly"},
+		{"weekly without hours", Schedule{ScheduleType: SCHEDULE_WEEKLY, ScheduleDays: "1"}, "Schedule hours cannot be empty"},
+		{"monthly", Schedule{ScheduleType: SCHEDULE_MONTHLY, ScheduleHours: "02:00", ScheduleDays: "1,15,L"}, ""},
+		{"monthly invalid day", Schedule{ScheduleType: SCHEDULE_MONTHLY, ScheduleHours: "02:00", ScheduleDays: "0"}, "must be between 1 and 31"},
+		{"monthly without hours", Schedule{ScheduleType: SCHEDULE_MONTHLY, ScheduleDays: "1"}, "Schedule hours cannot be empty"},
+		{"cron", Schedule{ScheduleType: SCHEDULE_CRON, CronExpression: "0 2 * * *"}, ""},
+		{"cron empty", Schedule{ScheduleType: SCHEDULE_CRON}, "Cron expression cannot be empty"},
+		{"cron invalid", Schedule{ScheduleType: SCHEDULE_CRON, CronExpression: "0 25 * * *"}, "out of range 0-23 in hour"},
+		{"cron with hours", Schedule{ScheduleType: SCHEDULE_CRON, CronExpression: "0 2 * * *", ScheduleHours: "02:00"}, "do not accept hours nor days"},
+		{"cron on daily", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "02:00", CronExpression: "0 2 * * *"}, "only applies to cron"},
+		{"unknown type", Schedule{ScheduleType: 42}, "not recognized"},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.schedule.Spec()
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("%s: Spec() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduleSpecRunsOnDay(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		days string
+		day  time.Time
+		want bool
+	}{
+		{"15", day(2018, time.January, 15), true},
+		{"15", day(2018, time.January, 16), false},
+		{"31", day(2018, time.January, 31), true},
+		{"31", day(2018, time.April, 30), true},
+		{"31", day(2018, time.April, 29), false},
+		{"30", day(2018, time.February, 28), true},
+		{"29", day(2020, time.February, 28), false},
+		{"29", day(2020, time.February, 29), true},
+		{"30", day(2020, time.February, 29), true},
+		{"L", day(2018, time.February, 28), true},
+		{"L", day(2018, time.March, 30), false},
+		{"L", day(2018, time.March, 31), true},
+		{"1,L", day(2018, time.March, 1), true},
+	}
+
+	for _, tt := range tests {
+		days, err := ParseMonthDays(tt.days)
+
+		if err != nil {
+			t.Fatalf("ParseMonthDays(%q) unexpected error %v", tt.days, err)
+		}
+
+		spec := ScheduleSpec{Type: SCHEDULE_MONTHLY, MonthDays: days}
+
+		if got := spec.RunsOnDay(tt.day); got != tt.want {
+			t.Errorf("RunsOnDay(%q, %s) = %t, want %t", tt.days, tt.day.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func errorContains(err error, want string) bool {
+	if want == "" {
+		return err == nil
+	}
+
+	return err != nil && strings.Contains(err.Error(), want)
+}
+
+func TestScheduleNextRuns(t *testing.T) {
+	from := time.Date(2018, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     []string
+	}{
+		{"hourly", Schedule{ScheduleType: SCHEDULE_HOURLY, ScheduleHours: "8"},
+			[]string{"2018-03-10T16:00:00Z", "2018-03-11T00:00:00Z", "2018-03-11T08:00:00Z"}},
+		{"daily", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "02:00,12:30"},
+			[]string{"2018-03-10T12:30:00Z", "2018-03-11T02:00:00Z", "2018-03-11T12:30:00Z"}},
+		{"weekly", Schedule{ScheduleType: SCHEDULE_WEEKLY, ScheduleHours: "02:00", ScheduleDays: "0,3"},
+			[]string{"2018-03-11T02:00:00Z", "2018-03-14T02:00:00Z", "2018-03-18T02:00:00Z"}},
+		{"monthly end of month", Schedule{ScheduleType: SCHEDULE_MONTHLY, ScheduleHours: "03:00", ScheduleDays: "31"},
+			[]string{"2018-03-31T03:00:00Z", "2018-04-30T03:00:00Z", "2018-05-31T03:00:00Z"}},
+		{"cron", Schedule{ScheduleType: SCHEDULE_CRON, CronExpression: "15 4 1 * *"},
+			[]string{"2018-04-01T04:15:00Z", "2018-05-01T04:15:00Z", "2018-06-01T04:15:00Z"}},
+		{"daily DST gap skipped", Schedule{ScheduleType: SCHEDULE_DAILY, ScheduleHours: "02:30",
+			Timezone: "America/New_York"},
+			[]string{"2018-03-12T02:30:00-04:00", "2018-03-13T02:30:00-04:00", "2018-03-14T02:30:00-04:00"}},
+		{"ondemand", Schedule{ScheduleType: SCHEDULE_ON_DEMAND}, nil},
+	}
+
+	for _, tt := range tests {
+		runs, err := tt.schedule.NextRuns(from, 3)
+
+		if err != nil {
+			t.Errorf("%s: NextRuns() unexpected error %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+
+		for _, r := range runs {
+			got = append(got, r.Format(time.RFC3339))
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: NextRuns() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// Script is a shell script the API generates to run in a server host
+type Script struct {
+	Body []byte
+
+	// Checksum is the hex encoded SHA-256 of Body sent by the API, if any
+	Checksum string
+
+	// Signature is the minisign or raw ed25519 signature of Body, empty if the
+	// API has none for this script
+	Signature []byte
+}
+
+func (c *Client) GetServerInstall(id int) (Script, error) {
+	return c.getServerScript(id, "install")
+}
+
+func (c *Client) GetServerUninstall(id int) (Script, error) {
+	return c.getServerScript(id, "uninstall")
+}
+
+func (c *Client) GetServerUpgrade(id int) (Script, error) {
+	return c.getServerScript(id, "upgrade")
+}
+
+// getServerScript downloads a server script along with its checksum and
+// signature, for ex: the install script for action 'install'
+func (c *Client) getServerScript(id int, action string) (script Script, err error) {
+	u := c.host + "/servers/" + strconv.Itoa(id) + "/" + action
+
+	script.Body, script.Checksum, err = c.getScriptFile(u, false)
+
+	if err != nil {
+		return
+	}
+
+	script.Signature, _, err = c.getScriptFile(u+"/signature", true)
+
+	return
+}
+
+// getScriptFile gets a raw file and its checksum header. If optional is set a
+// missing file is not an error, for ex: unsigned scripts are refused later on
+// unless explicitly allowed
+func (c *Client) getScriptFile(url string, optional bool) (body []byte, checksum string, err error) {
+	resp, err := c.httpClient.SignedGet(url, defaultHeaders)
+
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+
+	if optional && resp.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+
+	body, err = ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, "", wrap("while reading body", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, "", fmt.Errorf("Server responded HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, resp.Header.Get("X-Checksum-Sha256"), nil
+}
@@ -24,7 +24,7 @@ import (
 type Server struct {
 	ID       int          `json:"id"`
 	Name     string       `json:"name"`
-	DbType   databaseType `json:"dbTypeId"`
+	DbType   DatabaseType `json:"dbTypeId"`
 	Readonly bool         `json:"readonly"`
 	DbHost   string       `json:"dbHost"`
 	DbPort   string       `json:"dbPort"`
@@ -32,15 +32,15 @@ type Server struct {
 	DbPass   string       `json:"dbPass"`
 }
 
-type databaseType int
+type DatabaseType int
 
 const (
-	DB_MYSQL    databaseType = 1
-	DB_MONGO    databaseType = 2
-	DB_POSTGRES databaseType = 3
+	DB_MYSQL    DatabaseType = 1
+	DB_MONGO    DatabaseType = 2
+	DB_POSTGRES DatabaseType = 3
 )
 
-func (d databaseType) String() string {
+func (d DatabaseType) String() string {
 	switch d {
 	case DB_MONGO:
 		return "MongoDB"
@@ -55,7 +55,7 @@ func (d databaseType) String() string {
 	return "Unknown"
 }
 
-func ParseDatabaseType(s string) (databaseType, error) {
+func ParseDatabaseType(s string) (DatabaseType, error) {
 	switch strings.TrimSpace(strings.ToLower(s)) {
 	case "mongodb", "mongo":
 		return DB_MONGO, nil
@@ -82,7 +82,7 @@ func (s Server) JSONString() string {
 	return string(bs)
 }
 
-func (c *Client) CreateServer(name string, dbType databaseType, readonly bool,
+func (c *Client) CreateServer(name string, dbType DatabaseType, readonly bool,
 	dbHost, dbPort, dbUser, dbPass string) (server Server, err error) {
 
 	server = Server{
@@ -148,25 +148,7 @@ func (c *Client) UpdateServer(s Server) error {
 }
 
 func (c *Client) DeleteServer(id int) error {
-	resp, err := c.httpClient.SignedDelete(c.host+"/servers/"+strconv.Itoa(id), defaultHeaders)
-
-	if err != nil {
-		return err
-	}
-
-	body, val, err := c.httpClient.parseResponseJSON(resp)
-
-	if err != nil {
-		return err
-	}
-
-	_, err = c.httpClient.isJSONResponseOk(body, val)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return c.httpClient.deleteJSON(c.host + "/servers/" + strconv.Itoa(id))
 }
 
 func (c *Client) GetServer(id int) (server Server, err error) {
@@ -202,20 +184,8 @@ func (c *Client) GetServer(id int) (server Server, err error) {
 	return
 }
 
-func (c *Client) GetServerInstall(id int) (body []byte, err error) {
-	resp, err := c.httpClient.SignedGet(c.host+"/servers/"+strconv.Itoa(id)+"/install", defaultHeaders)
-
-	if err != nil {
-		return
-	}
-
-	defer resp.Body.Close()
-
-	body, err = ioutil.ReadAll(resp.Body)
-
-	if resp.StatusCode/100 != 2 {
-		err = fmt.Errorf("Server responded HTTP %d: %s", resp.StatusCode, string(body))
-	}
+func (c *Client) ListServers() (servers []Server, err error) {
+	err = c.httpClient.getJSON(c.host+"/servers", &servers)
 
 	return
 }
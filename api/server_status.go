@@ -0,0 +1,64 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ServerStatus is the agent status of a server as last reported to the API
+type ServerStatus struct {
+	ServerID      int       `json:"serverId"`
+	Online        bool      `json:"online"`
+	AgentVersion  string    `json:"agentVersion"`
+	LastHeartbeat Timestamp `json:"lastHeartbeat"`
+	Message       string    `json:"message,omitempty"`
+}
+
+// HeartbeatSince reports if the agent is online and sent a heartbeat after t
+func (s ServerStatus) HeartbeatSince(t time.Time) bool {
+	return s.Online && s.LastHeartbeat.After(t)
+}
+
+func (s ServerStatus) String() string {
+	heartbeat := "never"
+
+	if !s.LastHeartbeat.IsZero() {
+		heartbeat = s.LastHeartbeat.Format(time.RFC3339)
+	}
+
+	out := fmt.Sprintf("Server ID: %d\nOnline: %t\nAgent Version: %s\nLast Heartbeat: %s",
+		s.ServerID, s.Online, s.AgentVersion, heartbeat)
+
+	if s.Message != "" {
+		out += "\nMessage: " + s.Message
+	}
+
+	return out
+}
+
+func (s ServerStatus) JSONString() string {
+	bs, _ := json.Marshal(s)
+
+	return string(bs)
+}
+
+func (c *Client) GetServerStatus(id int) (status ServerStatus, err error) {
+	err = c.httpClient.getJSON(c.host+"/servers/"+strconv.Itoa(id)+"/status", &status)
+
+	return
+}
@@ -21,25 +21,41 @@ import (
 	"strings"
 )
 
+// Storage is a backup destination. The type specific settings live in Config,
+// whose concrete type is selected by StorageType when decoding JSON
 type Storage struct {
-	ID             int         `json:"id"`
-	Name           string      `json:"name"`
-	StorageType    StorageType `json:"storageType"`
-	LocalPath      string      `json:"localPath"`
-	Bucket         string      `json:"bucket"`
-	AccessKey      string      `json:"storage-access-key"`
-	SecretKey      string      `json:"storage-secret-key"`
-	RegionEndpoint string      `json:"region-endpoint"`
+	ID          int
+	Name        string
+	StorageType StorageType
+	Config      StorageConfig
+}
+
+// storageHeader holds the fields shared by every storage type
+type storageHeader struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	StorageType StorageType `json:"storageType"`
+}
+
+func NewStorage(name string, config StorageConfig) Storage {
+	return Storage{Name: name, StorageType: config.Type(), Config: config}
 }
 
 type StorageType int
 
 const (
-	STORAGE_LOCAL        StorageType = 1
-	STORAGE_S3           StorageType = 2
-	STORAGE_GOOGLE       StorageType = 5
-	STORAGE_DIGITALOCEAN StorageType = 6
-	STORAGE_ALIBABA      StorageType = 7
+	STORAGE_LOCAL         StorageType = 1
+	STORAGE_S3            StorageType = 2
+	STORAGE_GOOGLE        StorageType = 5
+	STORAGE_DIGITALOCEAN  StorageType = 6
+	STORAGE_ALIBABA       StorageType = 7
+	STORAGE_AZURE         StorageType = 8
+	STORAGE_B2            StorageType = 9
+	STORAGE_WASABI        StorageType = 10
+	STORAGE_S3_COMPATIBLE StorageType = 11
+	STORAGE_SFTP          StorageType = 12
+	STORAGE_NFS           StorageType = 13
+	STORAGE_SMB           StorageType = 14
 )
 
 /*
@@ -62,6 +78,20 @@ func (s StorageType) String() string {
 		return "DigitalOcean Spaces"
 	case STORAGE_ALIBABA:
 		return "Alibaba Object Storage"
+	case STORAGE_AZURE:
+		return "Azure Blob Storage"
+	case STORAGE_B2:
+		return "Backblaze B2"
+	case STORAGE_WASABI:
+		return "Wasabi"
+	case STORAGE_S3_COMPATIBLE:
+		return "S3 Compatible Storage"
+	case STORAGE_SFTP:
+		return "SFTP"
+	case STORAGE_NFS:
+		return "NFS Mount"
+	case STORAGE_SMB:
+		return "SMB Mount"
 	}
 
 	return "Unknown"
@@ -84,44 +114,151 @@ func ParseStorageType(s string) (StorageType, error) {
 	case "alibaba":
 		return STORAGE_ALIBABA, nil
 
+	case "azure":
+		return STORAGE_AZURE, nil
+
+	case "b2", "backblaze":
+		return STORAGE_B2, nil
+
+	case "wasabi":
+		return STORAGE_WASABI, nil
+
+	case "s3compatible", "minio", "ceph":
+		return STORAGE_S3_COMPATIBLE, nil
+
+	case "sftp":
+		return STORAGE_SFTP, nil
+
+	case "nfs":
+		return STORAGE_NFS, nil
+
+	case "smb", "cifs":
+		return STORAGE_SMB, nil
 	}
 
 	return 0, fmt.Errorf("Storage type %s not recognized", s)
 }
 
-func (s Storage) String() string {
-	switch s.StorageType {
-	case STORAGE_LOCAL:
-		return fmt.Sprintf("ID: %d\nName: %s\nStorage Type: %s\nPath: %s",
-			s.ID, s.Name, s.StorageType, s.LocalPath)
+// MarshalJSON flattens the config fields next to the storage header, as
+// expected by the API
+func (s Storage) MarshalJSON() ([]byte, error) {
+	header, err := json.Marshal(storageHeader{s.ID, s.Name, s.StorageType})
 
-	case STORAGE_S3, STORAGE_GOOGLE, STORAGE_DIGITALOCEAN, STORAGE_ALIBABA:
-		secret := "****"
+	if err != nil || s.Config == nil {
+		return header, err
+	}
 
-		if len(s.SecretKey) > 4 {
-			secret += s.SecretKey[len(s.SecretKey)-4:] //last 4 characters
-		}
+	config, err := json.Marshal(s.Config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(header, &fields); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
 
-		return fmt.Sprintf("ID: %d\nName: %s\nStorage Type: %s\nBucket: %s\nRegion Endpoint: %s\nAccess Key: %s\nSecret Key:%s",
-			s.ID, s.Name, s.StorageType, s.Bucket, s.RegionEndpoint, s.AccessKey, secret)
+// UnmarshalJSON uses the storageType field to pick the config to decode the
+// rest of the fields into. Unknown storage types are decoded without config
+func (s *Storage) UnmarshalJSON(b []byte) error {
+	var header storageHeader
+
+	if err := json.Unmarshal(b, &header); err != nil {
+		return err
 	}
 
-	return fmt.Sprintf("ID: %d\nName: %s\nStorage Type: %s", s.ID, s.Name, s.StorageType)
+	s.ID, s.Name, s.StorageType, s.Config = header.ID, header.Name, header.StorageType, nil
+
+	config, err := NewStorageConfig(header.StorageType)
+
+	if err != nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(b, config); err != nil {
+		return wrap("while decoding "+header.StorageType.String()+" config", err)
+	}
+
+	s.Config = config
+
+	return nil
 }
 
+// Validate checks the storage name and its type specific config
+func (s Storage) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("Storage name cannot be empty")
+	}
+
+	if s.Config == nil {
+		return fmt.Errorf("Storage type %s has no configuration", s.StorageType)
+	}
+
+	if s.Config.Type() != s.StorageType {
+		return fmt.Errorf("Storage type %s does not match its %s configuration",
+			s.StorageType, s.Config.Type())
+	}
+
+	return s.Config.Validate()
+}
+
+func (s Storage) String() string {
+	header := fmt.Sprintf("ID: %d\nName: %s\nStorage Type: %s", s.ID, s.Name, s.StorageType)
+
+	if s.Config == nil {
+		return header
+	}
+
+	return header + "\n" + s.Config.String()
+}
+
+// JSONString is the storage in JSON format with its secrets masked
 func (s Storage) JSONString() string {
 	bs, _ := json.Marshal(s)
 	j := string(bs)
 
-	if len(s.SecretKey) > 4 {
-		secret := "****" + s.SecretKey[len(s.SecretKey)-4:] //last 4 characters
+	if s.Config == nil {
+		return j
+	}
 
-		j = strings.Replace(j, s.SecretKey, secret, -1)
+	// secrets are matched as they are escaped in the JSON, for ex: the line
+	// breaks of a private key or the & of a password
+	for _, secret := range s.Config.Secrets() {
+		if len(secret) > 4 {
+			j = strings.Replace(j, jsonEscape(secret), jsonEscape(maskSecret(secret)), -1)
+		}
 	}
 
 	return j
 }
 
+// jsonEscape returns s as it is written inside a JSON string
+func jsonEscape(s string) string {
+	bs, _ := json.Marshal(s)
+
+	return string(bs[1 : len(bs)-1])
+}
+
+// maskSecret hides all but the last 4 characters of a secret
+func maskSecret(secret string) string {
+	masked := "****"
+
+	if len(secret) > 4 {
+		masked += secret[len(secret)-4:] //last 4 characters
+	}
+
+	return masked
+}
+
 func (c *Client) GetStorage(id int) (storage Storage, err error) {
 	resp, err := c.httpClient.SignedGet(c.host+"/storages/"+strconv.Itoa(id), defaultHeaders)
 
@@ -154,40 +291,27 @@ func (c *Client) GetStorage(id int) (storage Storage, err error) {
 	return
 }
 
-func (c *Client) DeleteStorage(id int) error {
-	resp, err := c.httpClient.SignedDelete(c.host+"/storages/"+strconv.Itoa(id), defaultHeaders)
+func (c *Client) ListStorages() (storages []Storage, err error) {
+	err = c.httpClient.getJSON(c.host+"/storages", &storages)
 
-	if err != nil {
-		return err
-	}
-
-	body, val, err := c.httpClient.parseResponseJSON(resp)
-
-	if err != nil {
-		return err
-	}
-
-	_, err = c.httpClient.isJSONResponseOk(body, val)
-
-	if err != nil {
-		return err
-	}
+	return
+}
 
-	return nil
+func (c *Client) DeleteStorage(id int) error {
+	return c.httpClient.deleteJSON(c.host + "/storages/" + strconv.Itoa(id))
 }
 
-func (c *Client) CreateStorage(name string, storageType StorageType,
-	localPath, bucket, region, accessKey, secretKey string) (storage Storage, err error) {
+func (c *Client) CreateStorage(storage Storage) (Storage, error) {
+	storage.ID = 0
 
-	storage = Storage{ID: 0, Name: name, StorageType: storageType, LocalPath: localPath,
-		Bucket: bucket, RegionEndpoint: region, AccessKey: accessKey, SecretKey: secretKey,
+	if err := storage.Validate(); err != nil {
+		return storage, err
 	}
 
 	val, err := c.httpClient.postJSON(c.host+"/storages", storage)
 
 	if err != nil {
-		err = wrap("while doing client post", err)
-		return
+		return storage, wrap("while doing client post", err)
 	}
 
 	if id, ok := val["id"]; ok {
@@ -200,7 +324,7 @@ func (c *Client) CreateStorage(name string, storageType StorageType,
 		err = fmt.Errorf("Missing ID from storage response %v", val)
 	}
 
-	return
+	return storage, err
 }
 
 func (c *Client) UpdateStorage(s Storage) error {
@@ -208,8 +332,8 @@ func (c *Client) UpdateStorage(s Storage) error {
 		return fmt.Errorf("Invalid ID %d for storage", s.ID)
 	}
 
-	if s.Name == "" {
-		return fmt.Errorf("Storage name cannot be empty")
+	if err := s.Validate(); err != nil {
+		return err
 	}
 
 	_, err := c.httpClient.postJSON(c.host+"/storages/"+strconv.Itoa(s.ID), s)
@@ -0,0 +1,366 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StorageConfig is the type specific configuration of a storage. Every field
+// tagged with `flag` is exposed as a command line flag, with `usage` as help,
+// and fields tagged with `secret` can also be read from a file, stdin or the
+// environment. Embedded structs are walked the same way
+type StorageConfig interface {
+	Type() StorageType
+
+	// Validate checks the config is complete for its storage type
+	Validate() error
+
+	// String prints the config fields, one per line, with secrets masked
+	String() string
+
+	// Secrets returns the sensitive values to mask when printing JSON
+	Secrets() []string
+}
+
+type storageBackend struct {
+	name      string
+	newConfig func() StorageConfig
+}
+
+var storageBackends = map[StorageType]storageBackend{
+	STORAGE_LOCAL:         {"local", func() StorageConfig { return &LocalConfig{} }},
+	STORAGE_S3:            {"s3", func() StorageConfig { return &S3Config{kind: STORAGE_S3} }},
+	STORAGE_GOOGLE:        {"google", func() StorageConfig { return &S3Config{kind: STORAGE_GOOGLE} }},
+	STORAGE_DIGITALOCEAN:  {"digitalocean", func() StorageConfig { return &S3Config{kind: STORAGE_DIGITALOCEAN} }},
+	STORAGE_ALIBABA:       {"alibaba", func() StorageConfig { return &S3Config{kind: STORAGE_ALIBABA} }},
+	STORAGE_AZURE:         {"azure", func() StorageConfig { return &AzureConfig{} }},
+	STORAGE_B2:            {"b2", func() StorageConfig { return &S3Config{kind: STORAGE_B2} }},
+	STORAGE_WASABI:        {"wasabi", func() StorageConfig { return &S3Config{kind: STORAGE_WASABI} }},
+	STORAGE_S3_COMPATIBLE: {"s3compatible", func() StorageConfig { return &S3CompatibleConfig{S3Config{kind: STORAGE_S3_COMPATIBLE}, false} }},
+	STORAGE_SFTP:          {"sftp", func() StorageConfig { return &SFTPConfig{Port: 22} }},
+	STORAGE_NFS:           {"nfs", func() StorageConfig { return &NFSConfig{} }},
+	STORAGE_SMB:           {"smb", func() StorageConfig { return &SMBConfig{} }},
+}
+
+// StorageTypes returns every known storage type, ordered by ID
+func StorageTypes() []StorageType {
+	types := make([]StorageType, 0, len(storageBackends))
+
+	for t := range storageBackends {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	return types
+}
+
+// Name is the short name of the storage type, as accepted by ParseStorageType
+func (s StorageType) Name() string {
+	return storageBackends[s].name
+}
+
+// NewStorageConfig returns an empty config for the storage type, with defaults set
+func NewStorageConfig(t StorageType) (StorageConfig, error) {
+	backend, ok := storageBackends[t]
+
+	if !ok {
+		return nil, fmt.Errorf("Storage type %d not recognized", int(t))
+	}
+
+	return backend.newConfig(), nil
+}
+
+func isEmpty(s string) bool {
+	return strings.TrimSpace(s) == ""
+}
+
+type LocalConfig struct {
+	Path string `json:"localPath" flag:"path" usage:"The local storage full path to store the backups (for ex: '/data/backups')"`
+}
+
+func (c *LocalConfig) Type() StorageType {
+	return STORAGE_LOCAL
+}
+
+func (c *LocalConfig) Validate() error {
+	if isEmpty(c.Path) {
+		return fmt.Errorf("Local path cannot be empty")
+	}
+
+	return nil
+}
+
+func (c *LocalConfig) String() string {
+	return fmt.Sprintf("Path: %s", c.Path)
+}
+
+func (c *LocalConfig) Secrets() []string {
+	return nil
+}
+
+// S3Config is shared by every storage speaking the S3 protocol
+type S3Config struct {
+	kind StorageType
+
+	Bucket         string `json:"bucket" flag:"bucket" usage:"The cloud bucket to store the backups into"`
+	AccessKey      string `json:"storage-access-key" flag:"storage-access-key" usage:"The access key for the cloud storage (the key ID for b2)"`
+	SecretKey      string `json:"storage-secret-key" flag:"storage-secret-key" usage:"The secret key for the cloud storage (the application key for b2)" secret:"true"`
+	RegionEndpoint string `json:"region-endpoint" flag:"region-endpoint" usage:"The cloud storage region endpoint, without https, as reported by your provider (for ex: 's3.ap-south-1.amazonaws.com')"`
+
+	CloudOptions
+}
+
+func (c *S3Config) Type() StorageType {
+	return c.kind
+}
+
+func (c *S3Config) Validate() error {
+	if isEmpty(c.Bucket) {
+		return fmt.Errorf("Storage bucket cannot be empty")
+	}
+
+	if isEmpty(c.AccessKey) {
+		return fmt.Errorf("Storage access key cannot be empty")
+	}
+
+	if isEmpty(c.SecretKey) {
+		return fmt.Errorf("Storage secret key cannot be empty")
+	}
+
+	if isEmpty(c.RegionEndpoint) {
+		return fmt.Errorf("Storage region endpoint cannot be empty")
+	}
+
+	switch c.kind {
+	case STORAGE_B2:
+		if !strings.Contains(c.RegionEndpoint, "backblazeb2.com") {
+			return fmt.Errorf("Backblaze B2 region endpoint must be a backblazeb2.com S3 endpoint "+
+				"(for ex: 's3.us-west-002.backblazeb2.com'), got '%s'", c.RegionEndpoint)
+		}
+
+	case STORAGE_WASABI:
+		if !strings.Contains(c.RegionEndpoint, "wasabisys.com") {
+			return fmt.Errorf("Wasabi region endpoint must be a wasabisys.com endpoint "+
+				"(for ex: 's3.eu-central-1.wasabisys.com'), got '%s'", c.RegionEndpoint)
+		}
+	}
+
+	return c.CloudOptions.validate(c.kind)
+}
+
+func (c *S3Config) String() string {
+	switch c.kind {
+	case STORAGE_B2:
+		return fmt.Sprintf("Bucket: %s\nRegion Endpoint: %s\nKey ID: %s\nApplication Key:%s%s",
+			c.Bucket, c.RegionEndpoint, c.AccessKey, maskSecret(c.SecretKey), c.CloudOptions.String())
+	}
+
+	return fmt.Sprintf("Bucket: %s\nRegion Endpoint: %s\nAccess Key: %s\nSecret Key:%s%s",
+		c.Bucket, c.RegionEndpoint, c.AccessKey, maskSecret(c.SecretKey), c.CloudOptions.String())
+}
+
+func (c *S3Config) Secrets() []string {
+	return []string{c.SecretKey}
+}
+
+// S3CompatibleConfig is a self hosted S3 storage such as MinIO or Ceph, where
+// RegionEndpoint is the custom endpoint
+type S3CompatibleConfig struct {
+	S3Config
+
+	PathStyle bool `json:"pathStyle" flag:"path-style" usage:"Use path style bucket addressing (bucket in the URL path instead of the host)"`
+}
+
+func (c *S3CompatibleConfig) String() string {
+	return fmt.Sprintf("Bucket: %s\nEndpoint: %s\nPath Style: %t\nAccess Key: %s\nSecret Key:%s%s",
+		c.Bucket, c.RegionEndpoint, c.PathStyle, c.AccessKey, maskSecret(c.SecretKey), c.CloudOptions.String())
+}
+
+type AzureConfig struct {
+	AccountName string `json:"accountName" flag:"azure-account" usage:"The Azure storage account name"`
+	AccountKey  string `json:"accountKey" flag:"azure-account-key" usage:"The Azure storage account key" secret:"true"`
+	Container   string `json:"container" flag:"container" usage:"The Azure blob container to store the backups into"`
+
+	CloudOptions
+}
+
+func (c *AzureConfig) Type() StorageType {
+	return STORAGE_AZURE
+}
+
+func (c *AzureConfig) Validate() error {
+	if isEmpty(c.AccountName) {
+		return fmt.Errorf("Azure account name cannot be empty")
+	}
+
+	if isEmpty(c.AccountKey) {
+		return fmt.Errorf("Azure account key cannot be empty")
+	}
+
+	if isEmpty(c.Container) {
+		return fmt.Errorf("Azure container cannot be empty")
+	}
+
+	return c.CloudOptions.validate(STORAGE_AZURE)
+}
+
+func (c *AzureConfig) String() string {
+	return fmt.Sprintf("Account Name: %s\nContainer: %s\nAccount Key:%s%s",
+		c.AccountName, c.Container, maskSecret(c.AccountKey), c.CloudOptions.String())
+}
+
+func (c *AzureConfig) Secrets() []string {
+	return []string{c.AccountKey}
+}
+
+type SFTPConfig struct {
+	Host       string `json:"sftpHost" flag:"sftp-host" usage:"The SFTP server host"`
+	Port       int    `json:"sftpPort" flag:"sftp-port" usage:"The SFTP server port"`
+	User       string `json:"sftpUser" flag:"sftp-user" usage:"The SFTP user"`
+	Password   string `json:"sftpPassword,omitempty" flag:"sftp-password" usage:"The SFTP password" secret:"true"`
+	PrivateKey string `json:"sftpPrivateKey,omitempty" flag:"sftp-private-key" usage:"The SFTP private key in PEM format, instead of password" secret:"true"`
+	RemotePath string `json:"remotePath" flag:"remote-path" usage:"The remote path to store the backups into"`
+}
+
+func (c *SFTPConfig) Type() StorageType {
+	return STORAGE_SFTP
+}
+
+func (c *SFTPConfig) Validate() error {
+	if isEmpty(c.Host) {
+		return fmt.Errorf("SFTP host cannot be empty")
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("SFTP port %d is not valid", c.Port)
+	}
+
+	if isEmpty(c.User) {
+		return fmt.Errorf("SFTP user cannot be empty")
+	}
+
+	if isEmpty(c.Password) && isEmpty(c.PrivateKey) {
+		return fmt.Errorf("SFTP password or private key must be set")
+	}
+
+	if isEmpty(c.RemotePath) {
+		return fmt.Errorf("SFTP remote path cannot be empty")
+	}
+
+	return nil
+}
+
+func (c *SFTPConfig) String() string {
+	auth := "Password"
+
+	if c.PrivateKey != "" {
+		auth = "Private Key"
+	}
+
+	return fmt.Sprintf("Host: %s\nPort: %d\nUser: %s\nAuthentication: %s\nRemote Path: %s",
+		c.Host, c.Port, c.User, auth, c.RemotePath)
+}
+
+func (c *SFTPConfig) Secrets() []string {
+	return []string{c.Password, c.PrivateKey}
+}
+
+// MountConfig holds the fields shared by the NFS and SMB network mounts
+type MountConfig struct {
+	MountPoint string `json:"localPath" flag:"path" usage:"The local mount point to store the backups into (for ex: '/mnt/backups')"`
+	Source     string `json:"mountSource" flag:"mount-source" usage:"The remote filesystem to mount"`
+	Options    string `json:"mountOptions,omitempty" flag:"mount-options" usage:"Extra mount options"`
+}
+
+func (c *MountConfig) validate() error {
+	if isEmpty(c.MountPoint) {
+		return fmt.Errorf("Mount point path cannot be empty")
+	}
+
+	if isEmpty(c.Source) {
+		return fmt.Errorf("Mount source cannot be empty")
+	}
+
+	return nil
+}
+
+func (c *MountConfig) String() string {
+	return fmt.Sprintf("Mount Source: %s\nMount Point: %s\nMount Options: %s",
+		c.Source, c.MountPoint, c.Options)
+}
+
+type NFSConfig struct {
+	MountConfig
+}
+
+func (c *NFSConfig) Type() StorageType {
+	return STORAGE_NFS
+}
+
+func (c *NFSConfig) Validate() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	if !strings.Contains(c.Source, ":") {
+		return fmt.Errorf("NFS mount source must be in 'host:/export' format, got '%s'", c.Source)
+	}
+
+	return nil
+}
+
+func (c *NFSConfig) Secrets() []string {
+	return nil
+}
+
+type SMBConfig struct {
+	MountConfig
+
+	User     string `json:"smbUser" flag:"smb-user" usage:"The SMB user"`
+	Password string `json:"smbPassword" flag:"smb-password" usage:"The SMB password" secret:"true"`
+	Domain   string `json:"smbDomain,omitempty" flag:"smb-domain" usage:"The SMB domain or workgroup"`
+}
+
+func (c *SMBConfig) Type() StorageType {
+	return STORAGE_SMB
+}
+
+func (c *SMBConfig) Validate() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(c.Source, "//") {
+		return fmt.Errorf("SMB mount source must be in '//host/share' format, got '%s'", c.Source)
+	}
+
+	if isEmpty(c.User) {
+		return fmt.Errorf("SMB user cannot be empty")
+	}
+
+	return nil
+}
+
+func (c *SMBConfig) String() string {
+	return fmt.Sprintf("%s\nUser: %s\nDomain: %s\nPassword:%s", c.MountConfig.String(),
+		c.User, c.Domain, maskSecret(c.Password))
+}
+
+func (c *SMBConfig) Secrets() []string {
+	return []string{c.Password}
+}
@@ -0,0 +1,192 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ENCRYPTION_NONE    = "none"
+	ENCRYPTION_SSE_S3  = "sse-s3"
+	ENCRYPTION_SSE_KMS = "sse-kms"
+
+	OBJECT_LOCK_GOVERNANCE = "governance"
+	OBJECT_LOCK_COMPLIANCE = "compliance"
+)
+
+// CloudOptions are the object level settings for cloud storages: server side
+// encryption, storage class, object prefix and object lock (immutability)
+type CloudOptions struct {
+	Encryption     string `json:"encryption,omitempty" flag:"encryption" usage:"Server side encryption: 'none', 'sse-s3' (provider managed keys) or 'sse-kms' (KMS managed keys)"`
+	KMSKeyID       string `json:"kmsKeyId,omitempty" flag:"kms-key-id" usage:"The KMS key ID or ARN for sse-kms encryption (the encryption scope for azure)"`
+	StorageClass   string `json:"storageClass,omitempty" flag:"storage-class" usage:"The storage class or access tier for the backups (for ex: 'STANDARD_IA', 'GLACIER_IR', 'NEARLINE', 'Cool')"`
+	ObjectPrefix   string `json:"objectPrefix,omitempty" flag:"object-prefix" usage:"The prefix (folder) to store the backups under, inside the bucket"`
+	ObjectLockMode string `json:"objectLockMode,omitempty" flag:"object-lock-mode" usage:"Object lock mode to make backups immutable: 'governance' or 'compliance'"`
+	ObjectLockDays int    `json:"objectLockDays,omitempty" flag:"object-lock-days" usage:"Days each backup is locked against deletion when object lock is enabled"`
+}
+
+// cloudCapabilities describes which cloud options a storage type supports
+type cloudCapabilities struct {
+	encryption []string
+
+	// storageClasses is nil when any class is accepted (self hosted storages)
+	storageClasses []string
+
+	// kmsKeyRequired is set when sse-kms has no provider default key
+	kmsKeyRequired bool
+	objectLock     bool
+}
+
+var cloudCapabilitiesByType = map[StorageType]cloudCapabilities{
+	STORAGE_S3: {
+		encryption: []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3, ENCRYPTION_SSE_KMS},
+		storageClasses: []string{"STANDARD", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING",
+			"GLACIER_IR", "GLACIER", "DEEP_ARCHIVE"},
+		objectLock: true,
+	},
+	STORAGE_GOOGLE: {
+		encryption:     []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3, ENCRYPTION_SSE_KMS},
+		storageClasses: []string{"STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE"},
+		kmsKeyRequired: true,
+	},
+	STORAGE_DIGITALOCEAN: {
+		encryption:     []string{ENCRYPTION_NONE},
+		storageClasses: []string{"STANDARD"},
+	},
+	STORAGE_ALIBABA: {
+		encryption:     []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3, ENCRYPTION_SSE_KMS},
+		storageClasses: []string{"Standard", "IA", "Archive", "ColdArchive"},
+	},
+	STORAGE_AZURE: {
+		encryption:     []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3, ENCRYPTION_SSE_KMS},
+		storageClasses: []string{"Hot", "Cool", "Cold", "Archive"},
+		kmsKeyRequired: true,
+		objectLock:     true,
+	},
+	STORAGE_B2: {
+		encryption:     []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3},
+		storageClasses: []string{"STANDARD"},
+		objectLock:     true,
+	},
+	STORAGE_WASABI: {
+		encryption:     []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3},
+		storageClasses: []string{"STANDARD"},
+		objectLock:     true,
+	},
+	STORAGE_S3_COMPATIBLE: {
+		encryption: []string{ENCRYPTION_NONE, ENCRYPTION_SSE_S3, ENCRYPTION_SSE_KMS},
+		objectLock: true,
+	},
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *CloudOptions) validate(t StorageType) error {
+	caps := cloudCapabilitiesByType[t]
+	encryption := strings.ToLower(strings.TrimSpace(o.Encryption))
+
+	if encryption != "" && !containsFold(caps.encryption, encryption) {
+		return fmt.Errorf("Encryption '%s' is not supported by %s (valid: %s)",
+			o.Encryption, t, strings.Join(caps.encryption, ", "))
+	}
+
+	o.Encryption = encryption
+
+	if encryption == ENCRYPTION_SSE_KMS {
+		if caps.kmsKeyRequired && isEmpty(o.KMSKeyID) {
+			return fmt.Errorf("KMS key ID is required for sse-kms encryption in %s", t)
+		}
+	} else if o.KMSKeyID != "" {
+		return fmt.Errorf("KMS key ID only applies to sse-kms encryption")
+	}
+
+	if o.StorageClass != "" && caps.storageClasses != nil && !containsFold(caps.storageClasses, o.StorageClass) {
+		return fmt.Errorf("Storage class '%s' is not supported by %s (valid: %s)",
+			o.StorageClass, t, strings.Join(caps.storageClasses, ", "))
+	}
+
+	// the providers expect the class in their own case
+	for _, class := range caps.storageClasses {
+		if strings.EqualFold(class, o.StorageClass) {
+			o.StorageClass = class
+		}
+	}
+
+	if strings.HasPrefix(o.ObjectPrefix, "/") || strings.Contains(o.ObjectPrefix, "//") ||
+		strings.Contains(o.ObjectPrefix, "\\") {
+
+		return fmt.Errorf("Object prefix '%s' cannot start with '/' nor contain '//' or '\\'", o.ObjectPrefix)
+	}
+
+	if o.ObjectLockMode == "" && o.ObjectLockDays == 0 {
+		return nil
+	}
+
+	if !caps.objectLock {
+		return fmt.Errorf("Object lock is not supported by %s", t)
+	}
+
+	switch strings.ToLower(o.ObjectLockMode) {
+	case OBJECT_LOCK_GOVERNANCE, OBJECT_LOCK_COMPLIANCE:
+	case "":
+		return fmt.Errorf("Object lock mode is required when object lock days are set")
+	default:
+		return fmt.Errorf("Object lock mode '%s' not recognized (valid: %s, %s)",
+			o.ObjectLockMode, OBJECT_LOCK_GOVERNANCE, OBJECT_LOCK_COMPLIANCE)
+	}
+
+	if o.ObjectLockDays <= 0 {
+		return fmt.Errorf("Object lock days must be > 0 when object lock mode is set")
+	}
+
+	return nil
+}
+
+// String prints the options that are set, each in its own line preceded by a
+// line break, to be appended to the storage config output
+func (o *CloudOptions) String() string {
+	out := ""
+
+	if o.Encryption != "" {
+		out += "\nEncryption: " + o.Encryption
+
+		if o.KMSKeyID != "" {
+			out += "\nKMS Key ID: " + o.KMSKeyID
+		}
+	}
+
+	if o.StorageClass != "" {
+		out += "\nStorage Class: " + o.StorageClass
+	}
+
+	if o.ObjectPrefix != "" {
+		out += "\nObject Prefix: " + o.ObjectPrefix
+	}
+
+	if o.ObjectLockMode != "" {
+		out += fmt.Sprintf("\nObject Lock: %s, %d days", o.ObjectLockMode, o.ObjectLockDays)
+	}
+
+	return out
+}
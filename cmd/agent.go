@@ -0,0 +1,273 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/agent"
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+// Nagios plugin exit codes
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// checkSeverity orders the states so UNKNOWN only wins over OK, as a known
+// problem is more useful to report
+var checkSeverity = []int{0, 2, 3, 1}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Inspect the CloudBackup agent installed in this host",
+}
+
+var agentStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Check the health of the agent in this host, usable as a Nagios check",
+	Long: "Check the health of the agent in this host, usable as a Nagios check.\n\n" +
+		"Inspects the agent systemd unit, version, config file and log, and compares them with the " +
+		"server status reported by the API. The server ID is read from the agent config if --server-id " +
+		"is not set. Exits 0 if healthy, 1 on warnings, 2 if unhealthy and 3 if the health is unknown.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report := checkAgent(cmd)
+
+		if getBoolFlag(cmd, "json") {
+			bs, err := json.Marshal(report)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(bs))
+		} else {
+			report.print()
+		}
+
+		if err := recordValue(report, false); err != nil {
+			return err
+		}
+
+		if report.State != checkOK {
+			exitMachine(cmd, report.State, fmt.Errorf("Agent is %s", checkStateNames[report.State]))
+		}
+
+		return nil
+	},
+}
+
+type agentCheck struct {
+	Name    string `json:"name"`
+	State   int    `json:"state"`
+	Message string `json:"message"`
+}
+
+type agentReport struct {
+	State   int               `json:"state"`
+	Unit    agent.Unit        `json:"unit"`
+	Version string            `json:"version"`
+	Config  agent.Config      `json:"config"`
+	Server  *api.ServerStatus `json:"server,omitempty"`
+	Checks  []agentCheck      `json:"checks"`
+	Log     []string          `json:"log"`
+}
+
+// check records the result of the check name, replacing a previous result of
+// the same check, and updates the overall state
+func (r *agentReport) check(name string, state int, format string, args ...interface{}) {
+	c := agentCheck{name, state, fmt.Sprintf(format, args...)}
+	replaced := false
+
+	for i := range r.Checks {
+		if r.Checks[i].Name == name {
+			r.Checks[i] = c
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		r.Checks = append(r.Checks, c)
+	}
+
+	r.State = checkOK
+
+	for _, c := range r.Checks {
+		if checkSeverity[c.State] > checkSeverity[r.State] {
+			r.State = c.State
+		}
+	}
+}
+
+func checkAgent(cmd *cobra.Command) agentReport {
+	var r agentReport
+	var err error
+
+	service := getStringFlag(cmd, "agent-service")
+
+	if r.Unit, err = agent.UnitStatus(service); err != nil {
+		r.check("unit", checkUnknown, "%s", err)
+	} else if !r.Unit.Running() {
+		r.check("unit", checkCritical, "%s is %s (%s)", service, r.Unit.ActiveState, r.Unit.SubState)
+	} else {
+		r.check("unit", checkOK, "%s is running", service)
+	}
+
+	if r.Version, err = agent.Version(getStringFlag(cmd, "agent-binary")); err != nil {
+		r.check("version", checkWarning, "%s", err)
+	} else {
+		r.check("version", checkOK, "agent %s", r.Version)
+	}
+
+	if r.Config, err = agent.ReadConfig(getStringFlag(cmd, "agent-config")); os.IsPermission(err) {
+		r.check("config", checkUnknown, "%s, run as root to check the config", err)
+	} else if err != nil {
+		r.check("config", checkCritical, "%s", err)
+	} else if r.Config.WorldReadable() {
+		r.check("config", checkWarning, "%s is readable by every user (%s)", r.Config.Path, r.Config.Mode.Perm())
+	} else {
+		r.check("config", checkOK, "%s", r.Config.Path)
+	}
+
+	checkServerStatus(cmd, &r)
+
+	if r.Log, err = agent.LogTail(service, getStringFlag(cmd, "log-file"), getIntFlag(cmd, "log-lines")); err != nil {
+		r.check("log", checkWarning, "%s", err)
+	}
+
+	return r
+}
+
+// checkServerStatus compares the local agent with the server status in the API
+func checkServerStatus(cmd *cobra.Command, r *agentReport) {
+	serverID := getIntFlag(cmd, "server-id")
+
+	if serverID == 0 {
+		serverID = r.Config.ServerID
+	}
+
+	if serverID == 0 {
+		r.check("api", checkUnknown, "no server ID in the agent config, pass --server-id")
+		return
+	}
+
+	client, err := newAPIClient()
+
+	if err != nil {
+		r.check("api", checkUnknown, "%s", err)
+		return
+	}
+
+	status, err := client.GetServerStatus(serverID)
+
+	if err != nil {
+		r.check("api", checkUnknown, "%s", err)
+		return
+	}
+
+	r.Server = &status
+	maxAge, _ := cmd.Flags().GetDuration("max-heartbeat-age")
+
+	switch {
+	case !status.Online:
+		r.check("api", checkCritical, "server %d is offline in the API", serverID)
+
+	case status.LastHeartbeat.IsZero() || time.Since(status.LastHeartbeat.Time) > maxAge:
+		r.check("api", checkCritical, "server %d last heartbeat is older than %s", serverID, maxAge)
+
+	default:
+		r.check("api", checkOK, "server %d heartbeat %s ago", serverID,
+			time.Since(status.LastHeartbeat.Time).Truncate(time.Second))
+	}
+
+	if r.Version != "" && status.AgentVersion != "" && r.Version != status.AgentVersion {
+		r.check("version", checkWarning, "installed agent %s but the API reports %s, restart pending?",
+			r.Version, status.AgentVersion)
+	}
+}
+
+func (r agentReport) print() {
+	var problems []string
+
+	for _, c := range r.Checks {
+		if c.State != checkOK {
+			problems = append(problems, c.Message)
+		}
+	}
+
+	summary := "agent healthy"
+
+	if len(problems) > 0 {
+		summary = strings.Join(problems, "; ")
+	}
+
+	fmt.Printf("AGENT %s - %s\n\n", checkStateNames[r.State], summary)
+	fmt.Println("Unit:", r.Unit)
+	fmt.Println("Version:", r.Version)
+
+	if r.Config.ModTime.IsZero() {
+		fmt.Printf("Config: %s (unreadable)\n", r.Config.Path)
+	} else {
+		fmt.Printf("Config: %s (%s, modified %s)\n", r.Config.Path, r.Config.Mode.Perm(), r.Config.ModTime.Format(time.RFC3339))
+	}
+
+	if r.Server != nil {
+		heartbeat := "never"
+
+		if !r.Server.LastHeartbeat.IsZero() {
+			heartbeat = r.Server.LastHeartbeat.Format(time.RFC3339)
+		}
+
+		fmt.Printf("API: server %d online %t, agent %s, last heartbeat %s\n",
+			r.Server.ServerID, r.Server.Online, r.Server.AgentVersion, heartbeat)
+	}
+
+	fmt.Println("\nChecks:")
+
+	for _, c := range r.Checks {
+		fmt.Printf("  %-8s %-8s %s\n", checkStateNames[c.State], c.Name, c.Message)
+	}
+
+	if len(r.Log) > 0 {
+		fmt.Printf("\nLog (last %d lines):\n", len(r.Log))
+
+		for _, line := range r.Log {
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentStatus)
+
+	agentStatus.Flags().Bool("json", false, "Output the status in JSON format")
+	agentStatus.Flags().Int("server-id", 0, "Server ID, read from the agent config if not set")
+	agentStatus.Flags().String("agent-service", agent.DefaultService, "The agent systemd service")
+	agentStatus.Flags().String("agent-binary", agent.DefaultBinary, "The agent binary")
+	agentStatus.Flags().String("agent-config", agent.DefaultConfigFile, "The agent config file")
+	agentStatus.Flags().String("log-file", agent.DefaultLogFile, "The agent log file, the systemd journal is used if it does not exist")
+	agentStatus.Flags().Int("log-lines", 10, "How many log lines to show")
+	agentStatus.Flags().Duration("max-heartbeat-age", 5*time.Minute, "Consider the agent unhealthy if its last heartbeat is older than this")
+}
@@ -15,7 +15,11 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/binlogicinc/cloudbackup-cli/api"
 	"github.com/spf13/cobra"
+	"os"
+	"os/user"
+	"time"
 )
 
 var backupsCmd = &cobra.Command{
@@ -40,8 +44,159 @@ var backupListKeys = &cobra.Command{
 	},
 }
 
+var backupHold = &cobra.Command{
+	Use:   "hold",
+	Short: "Place a hold on a backup so retention policies never delete it",
+	Long: "Place a hold on a backup so retention policies never delete it.\n\n" +
+		"The hold records who placed it and when. Without --until it lasts until released " +
+		"with 'backup release'.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hold := api.BackupHold{
+			BackupID: getIntFlag(cmd, "backup-id"),
+			Reason:   getStringFlag(cmd, "reason"),
+			PlacedBy: holdOwner(),
+			PlacedAt: api.Timestamp{Time: time.Now().UTC()},
+		}
+
+		if until := getStringFlag(cmd, "until"); until != "" {
+			t, err := parseTimeFlag(until, time.Local)
+
+			if err != nil {
+				return err
+			}
+
+			hold.Until = api.Timestamp{Time: t}
+		}
+
+		hold, err := getAPIClient().CreateHold(hold)
+
+		if err != nil {
+			return err
+		}
+
+		printVerbose("Hold placed successfully")
+		printResource(cmd, hold, true)
+
+		return nil
+	},
+}
+
+var backupRelease = &cobra.Command{
+	Use:   "release",
+	Short: "Release a backup hold, making the backup subject to retention again",
+	Long: "Release a backup hold, making the backup subject to retention again.\n\n" +
+		"Pass --hold-id to release a single hold, or --backup-id to release every hold of a backup.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		holdID, backupID := getIntFlag(cmd, "hold-id"), getIntFlag(cmd, "backup-id")
+
+		if (holdID == 0) == (backupID == 0) {
+			return fmt.Errorf("Either --hold-id or --backup-id must be set")
+		}
+
+		client := getAPIClient()
+		ids := []int{holdID}
+
+		if backupID > 0 {
+			holds, err := client.ListHolds(backupID)
+
+			if err != nil {
+				return err
+			}
+
+			if len(holds) == 0 {
+				return fmt.Errorf("Backup %d has no holds", backupID)
+			}
+
+			ids = ids[:0]
+
+			for _, h := range holds {
+				ids = append(ids, h.ID)
+			}
+		}
+
+		for _, id := range ids {
+			if err := recordDelete(id, client.DeleteHold(id)); err != nil {
+				return err
+			}
+
+			printVerbose("Hold %d released successfully", id)
+		}
+
+		return nil
+	},
+}
+
+var backupHolds = &cobra.Command{
+	Use:   "holds",
+	Short: "Manage the holds that protect backups from retention",
+}
+
+var backupHoldsList = &cobra.Command{
+	Use:     "list",
+	Short:   "List backup holds, including expired ones",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		holds, err := getAPIClient().ListHolds(getIntFlag(cmd, "backup-id"))
+
+		if err != nil {
+			return err
+		}
+
+		now, printed := time.Now(), 0
+
+		for _, h := range holds {
+			if !h.Active(now) && !getBoolFlag(cmd, "all") {
+				continue
+			}
+
+			if printed > 0 && !getBoolFlag(cmd, "json") {
+				fmt.Println()
+			}
+
+			printResource(cmd, h, false)
+			printed++
+		}
+
+		return nil
+	},
+}
+
+// holdOwner identifies who places a hold, as user@host
+func holdOwner() string {
+	name := "unknown"
+
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+
+	return name
+}
+
 func init() {
 	RootCmd.AddCommand(backupsCmd)
 	backupsCmd.AddCommand(backupListKeys)
+	backupsCmd.AddCommand(backupHold)
+	backupsCmd.AddCommand(backupRelease)
+	backupsCmd.AddCommand(backupHolds)
+	backupHolds.AddCommand(backupHoldsList)
+
+	backupHold.Flags().Bool("json", false, "Output info in JSON format")
+	backupHold.Flags().Int("backup-id", 0, "Backup ID")
+	backupHold.MarkFlagRequired("backup-id")
+	backupHold.Flags().String("reason", "", "Why the backup must be kept, for ex: 'Audit 2018-Q3'")
+	backupHold.MarkFlagRequired("reason")
+	backupHold.Flags().String("until", "", "When the hold expires (RFC 3339, 'YYYY-MM-DD HH:MM' or 'YYYY-MM-DD'), indefinite if not set")
+
+	backupRelease.Flags().Int("hold-id", 0, "Hold ID")
+	backupRelease.Flags().Int("backup-id", 0, "Release every hold of this backup ID")
 
+	backupHoldsList.Flags().Bool("json", false, "Output info in JSON format")
+	backupHoldsList.Flags().Int("backup-id", 0, "Only list the holds of this backup ID")
+	backupHoldsList.Flags().Bool("all", false, "Include expired holds")
 }
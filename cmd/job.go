@@ -0,0 +1,323 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Create, update, remove and get information for backup jobs in Binlogic CloudBackup",
+	Long: "Create, update, remove and get information for backup jobs in Binlogic CloudBackup.\n\n" +
+		"A job is the backup plan of a server: it ties the server to a storage, a schedule and " +
+		"a retention policy, along with the backup options.",
+}
+
+var jobNew = &cobra.Command{
+	Use:     "new",
+	Short:   "Add new backup job to Binlogic CloudBackup",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		job := api.BackupJob{Enabled: !getBoolFlag(cmd, "disabled")}
+
+		if err := setJobFlags(cmd, &job, false); err != nil {
+			return err
+		}
+
+		job, err := getAPIClient().CreateJob(job)
+
+		if err != nil {
+			return err
+		}
+
+		printVerbose("Job created successfully")
+		printResource(cmd, job, true)
+
+		return nil
+	},
+}
+
+var jobUpdate = &cobra.Command{
+	Use:     "update",
+	Short:   "Updates a backup job in Binlogic CloudBackup. Only the flags passed are updated",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := getIntFlag(cmd, "job-id")
+
+		if jobID == 0 {
+			return fmt.Errorf("Job ID cannot be zero")
+		}
+
+		job, err := getAPIClient().GetJob(jobID)
+
+		if err != nil {
+			return err
+		}
+
+		before := snapshot(job)
+
+		if err := setJobFlags(cmd, &job, true); err != nil {
+			return err
+		}
+
+		if unchanged(before, job) {
+			printVerbose("Job is up to date")
+			printResource(cmd, job, false)
+
+			return nil
+		}
+
+		if err := getAPIClient().UpdateJob(job); err != nil {
+			return err
+		}
+
+		printResource(cmd, job, true)
+
+		return nil
+	},
+}
+
+var jobDelete = &cobra.Command{
+	Use:     "delete",
+	Short:   "Delete a backup job in Binlogic CloudBackup",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := getIntFlag(cmd, "job-id")
+
+		if jobID == 0 {
+			return fmt.Errorf("Job ID cannot be zero")
+		}
+
+		if err := recordDelete(jobID, getAPIClient().DeleteJob(jobID)); err != nil {
+			return err
+		}
+
+		printVerbose("Job deleted successfully")
+
+		return nil
+	},
+}
+
+var jobInfo = &cobra.Command{
+	Use:     "info",
+	Short:   "Get information for a backup job in Binlogic CloudBackup",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobID := getIntFlag(cmd, "job-id")
+
+		if jobID == 0 {
+			return fmt.Errorf("Job ID cannot be zero")
+		}
+
+		if job, err := getAPIClient().GetJob(jobID); err != nil {
+			return err
+		} else {
+			printResource(cmd, job, false)
+		}
+
+		return nil
+	},
+}
+
+var jobList = &cobra.Command{
+	Use:     "list",
+	Short:   "List the backup jobs in Binlogic CloudBackup",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobs, err := getAPIClient().ListJobs()
+
+		if err != nil {
+			return err
+		}
+
+		serverID, printed := getIntFlag(cmd, "server-id"), 0
+
+		for _, job := range jobs {
+			if serverID > 0 && job.ServerID != serverID {
+				continue
+			}
+
+			if printed > 0 && !getBoolFlag(cmd, "json") {
+				fmt.Println()
+			}
+
+			printResource(cmd, job, false)
+			printed++
+		}
+
+		return nil
+	},
+}
+
+// jobEnableCmd creates the 'job enable' and 'job disable' commands
+func jobEnableCmd(enable bool) *cobra.Command {
+	use, short := "enable", "Enable a backup job so it runs on its schedule"
+
+	if !enable {
+		use, short = "disable", "Disable a backup job so it does not run until enabled again"
+	}
+
+	cmd := &cobra.Command{
+		Use:     use,
+		Short:   short,
+		PreRunE: checkRequiredFlags,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobID := getIntFlag(cmd, "job-id")
+
+			if jobID == 0 {
+				return fmt.Errorf("Job ID cannot be zero")
+			}
+
+			client := getAPIClient()
+			job, err := client.GetJob(jobID)
+
+			if err != nil {
+				return err
+			}
+
+			if job.Enabled == enable {
+				printVerbose("Job is already %sd", use)
+				recordResource(job, false)
+
+				return nil
+			}
+
+			job.Enabled = enable
+
+			if err := client.UpdateJob(job); err != nil {
+				return err
+			}
+
+			printVerbose("Job %sd successfully", use)
+			recordResource(job, true)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Int("job-id", 0, "Job ID")
+	cmd.MarkFlagRequired("job-id")
+
+	return cmd
+}
+
+// setJobFlags copies the job flags into job. If onlyChanged is set, the flags
+// not passed in the command line are ignored
+func setJobFlags(cmd *cobra.Command, job *api.BackupJob, onlyChanged bool) error {
+	set := func(name string) bool {
+		return !onlyChanged || cmd.Flags().Changed(name)
+	}
+
+	if set("name") {
+		job.Name = getStringFlag(cmd, "name")
+	}
+
+	ids := map[string]*int{
+		"server-id":    &job.ServerID,
+		"storage-id":   &job.StorageID,
+		"schedule-id":  &job.ScheduleID,
+		"retention-id": &job.RetentionID,
+	}
+
+	for name, id := range ids {
+		if set(name) {
+			*id = getIntFlag(cmd, name)
+		}
+	}
+
+	if set("backup-type") {
+		backupType, err := api.ParseBackupType(getStringFlag(cmd, "backup-type"))
+
+		if err != nil {
+			return err
+		}
+
+		job.BackupType = backupType
+	}
+
+	if set("compression-level") {
+		job.CompressionLevel = getIntFlag(cmd, "compression-level")
+	}
+
+	if set("include-databases") {
+		job.IncludeDatabases, _ = cmd.Flags().GetStringSlice("include-databases")
+	}
+
+	if set("exclude-databases") {
+		job.ExcludeDatabases, _ = cmd.Flags().GetStringSlice("exclude-databases")
+	}
+
+	// when updating, passing one database list clears the other, so a job can
+	// switch from including to excluding databases in a single update
+	include, exclude := cmd.Flags().Changed("include-databases"), cmd.Flags().Changed("exclude-databases")
+
+	if onlyChanged && include && !exclude {
+		job.ExcludeDatabases = nil
+	} else if onlyChanged && exclude && !include {
+		job.IncludeDatabases = nil
+	}
+
+	return nil
+}
+
+func addJobFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("json", false, "Output info in JSON format")
+
+	cmd.Flags().String("name", "", "The job name to show in the control panel")
+	cmd.Flags().Int("server-id", 0, "The server to back up")
+	cmd.Flags().Int("storage-id", 0, "The storage to send the backups to")
+	cmd.Flags().Int("schedule-id", 0, "The schedule the backups run on")
+	cmd.Flags().Int("retention-id", 0, "The retention policy of the backups")
+
+	cmd.Flags().String("backup-type", "full", "The backup type (full or incremental)")
+	cmd.Flags().Int("compression-level", 0, "Compression level from 1 (fastest) to 9 (smallest), 0 for the agent default")
+	cmd.Flags().StringSlice("include-databases", nil, "Only back up these databases (comma separated)")
+	cmd.Flags().StringSlice("exclude-databases", nil, "Back up every database but these (comma separated)")
+}
+
+func init() {
+	RootCmd.AddCommand(jobCmd)
+	jobCmd.AddCommand(jobNew)
+	jobCmd.AddCommand(jobUpdate)
+	jobCmd.AddCommand(jobDelete)
+	jobCmd.AddCommand(jobInfo)
+	jobCmd.AddCommand(jobList)
+	jobCmd.AddCommand(jobEnableCmd(true))
+	jobCmd.AddCommand(jobEnableCmd(false))
+
+	addJobFlags(jobNew)
+	jobNew.Flags().Bool("disabled", false, "Create the job disabled")
+
+	for _, name := range []string{"name", "server-id", "storage-id", "schedule-id", "retention-id"} {
+		jobNew.MarkFlagRequired(name)
+	}
+
+	addJobFlags(jobUpdate)
+	jobUpdate.Flags().Int("job-id", 0, "Job ID")
+	jobUpdate.MarkFlagRequired("job-id")
+
+	jobDelete.Flags().Int("job-id", 0, "Job ID")
+	jobDelete.MarkFlagRequired("job-id")
+
+	jobInfo.Flags().Int("job-id", 0, "Job ID")
+	jobInfo.MarkFlagRequired("job-id")
+	jobInfo.Flags().Bool("json", false, "Output info in JSON format")
+
+	jobList.Flags().Bool("json", false, "Output info in JSON format")
+	jobList.Flags().Int("server-id", 0, "Only list the jobs of this server ID")
+}
@@ -0,0 +1,178 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+// machine is set by --machine. In machine mode every command prints a single
+// JSON line to stdout with its result, and the human output goes to stderr
+var machine bool
+
+// machineOut is the real stdout in machine mode
+var machineOut io.Writer = os.Stdout
+
+// executingCmd is the command being run, to name it in the machine result
+var executingCmd *cobra.Command
+
+// machineLine is the JSON line printed by a command in machine mode
+type machineLine struct {
+	Command   string            `json:"command"`
+	OK        bool              `json:"ok"`
+	Changed   bool              `json:"changed"`
+	IDs       []int             `json:"ids"`
+	Resources []json.RawMessage `json:"resources"`
+	Error     string            `json:"error,omitempty"`
+}
+
+var machineResult = machineLine{IDs: []int{}, Resources: []json.RawMessage{}}
+
+// resource is anything the API manages, printed as text or JSON
+type resource interface {
+	String() string
+	JSONString() string
+}
+
+// startMachineMode sends the human output to stderr, keeping stdout for the
+// machine result
+func startMachineMode(cmd *cobra.Command, args []string) {
+	executingCmd = cmd
+
+	if !machine {
+		return
+	}
+
+	machineOut = os.Stdout
+	os.Stdout = os.Stderr
+	cmd.SilenceUsage = true
+}
+
+// printResource prints r as text, as JSON with --json, or records it for the
+// machine result. changed tells if the command modified the resource
+func printResource(cmd *cobra.Command, r resource, changed bool) {
+	if machine {
+		recordResource(r, changed)
+	} else if getBoolFlag(cmd, "json") {
+		fmt.Println(r.JSONString())
+	} else {
+		fmt.Println(r)
+	}
+}
+
+// recordResource adds r to the machine result without printing it
+func recordResource(r resource, changed bool) {
+	if machine {
+		recordJSON(json.RawMessage(r.JSONString()), changed)
+	}
+}
+
+// recordValue adds v, marshalled to JSON, to the machine result. Used for the
+// command reports that are not API resources
+func recordValue(v interface{}, changed bool) error {
+	if !machine {
+		return nil
+	}
+
+	bs, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	recordJSON(bs, changed)
+
+	return nil
+}
+
+func recordJSON(raw json.RawMessage, changed bool) {
+	var header struct {
+		ID int `json:"id"`
+	}
+
+	if err := json.Unmarshal(raw, &header); err == nil && header.ID > 0 {
+		machineResult.IDs = append(machineResult.IDs, header.ID)
+	}
+
+	machineResult.Resources = append(machineResult.Resources, raw)
+	machineResult.Changed = machineResult.Changed || changed
+}
+
+// recordChange records a change without a resource to print, for ex: the ID
+// of a deleted resource
+func recordChange(id int) {
+	machineResult.IDs = append(machineResult.IDs, id)
+	machineResult.Changed = true
+}
+
+// recordDelete records the outcome of deleting id. A resource that is already
+// gone is not an error, just not a change, so delete commands can be rerun
+func recordDelete(id int, err error) error {
+	if err == api.ErrNotFound {
+		printVerbose("%d was already deleted", id)
+		machineResult.IDs = append(machineResult.IDs, id)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	recordChange(id)
+
+	return nil
+}
+
+// emitMachineResult prints the machine result of cmd, failed if err is set
+func emitMachineResult(cmd *cobra.Command, err error) {
+	if !machine || cmd == nil {
+		return
+	}
+
+	machineResult.Command = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	machineResult.OK = err == nil
+
+	if err != nil {
+		machineResult.Error = err.Error()
+	}
+
+	bs, _ := json.Marshal(machineResult)
+	fmt.Fprintln(machineOut, string(bs))
+}
+
+// exitMachine exits with code, printing the machine result first. Used by the
+// commands that exit without returning an error
+func exitMachine(cmd *cobra.Command, code int, err error) {
+	emitMachineResult(cmd, err)
+	os.Exit(code)
+}
+
+// snapshot marshals v to later tell if it was modified with unchanged
+func snapshot(v interface{}) []byte {
+	bs, _ := json.Marshal(v)
+
+	return bs
+}
+
+// unchanged reports if v marshals to the same JSON as the snapshot before
+func unchanged(before []byte, v interface{}) bool {
+	return string(before) == string(snapshot(v))
+}
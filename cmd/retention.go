@@ -17,7 +17,9 @@ import (
 	"fmt"
 	"github.com/binlogicinc/cloudbackup-cli/api"
 	"github.com/spf13/cobra"
-	"os"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 var retentionCmd = &cobra.Command{
@@ -30,30 +32,31 @@ var retentionNew = &cobra.Command{
 	Short:   "Add new retention policy to Binlogic CloudBackup",
 	PreRunE: checkRequiredFlags,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := getStringFlag(cmd, "name")
-		retType := getStringFlag(cmd, "retention-type")
-		count := getIntFlag(cmd, "count")
-
-		retentionType, err := api.ParseRetentionType(retType)
+		retentionType, err := api.ParseRetentionType(getStringFlag(cmd, "retention-type"))
 
 		if err != nil {
 			return err
 		}
 
-		retention, err := getAPIClient().CreateRetention(name, retentionType, count)
+		retention := api.Retention{
+			Name:          getStringFlag(cmd, "name"),
+			RetentionType: retentionType,
+			Count:         getIntFlag(cmd, "count"),
+		}
 
-		if err != nil {
+		if err := setRetentionFlags(cmd, &retention, false); err != nil {
 			return err
 		}
 
-		printVerbose("Retention created successfully")
+		retention, err = getAPIClient().CreateRetention(retention)
 
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(retention.JSONString())
-		} else {
-			fmt.Println(retention)
+		if err != nil {
+			return err
 		}
 
+		printVerbose("Retention created successfully")
+		printResource(cmd, retention, true)
+
 		return nil
 	},
 }
@@ -75,15 +78,17 @@ var retentionUpdate = &cobra.Command{
 			return err
 		}
 
-		if flag := cmd.Flag("name"); flag != nil {
+		before := snapshot(retention)
+
+		if flag := cmd.Flag("name"); flag.Changed {
 			retention.Name = flag.Value.String()
 		}
 
-		if flag := cmd.Flag("count"); flag != nil {
+		if flag := cmd.Flag("count"); flag.Changed {
 			retention.Count = getIntFlag(cmd, "count")
 		}
 
-		if flag := cmd.Flag("retention-type"); flag != nil {
+		if flag := cmd.Flag("retention-type"); flag.Changed {
 			newRetentionType, err := api.ParseRetentionType(flag.Value.String())
 
 			if err != nil {
@@ -93,16 +98,23 @@ var retentionUpdate = &cobra.Command{
 			retention.RetentionType = newRetentionType
 		}
 
-		if err := getAPIClient().UpdateRetention(retention); err != nil {
+		if err := setRetentionFlags(cmd, &retention, true); err != nil {
 			return err
 		}
 
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(retention.JSONString())
-		} else {
-			fmt.Println(retention)
+		if unchanged(before, retention) {
+			printVerbose("Retention is up to date")
+			printResource(cmd, retention, false)
+
+			return nil
+		}
+
+		if err := getAPIClient().UpdateRetention(retention); err != nil {
+			return err
 		}
 
+		printResource(cmd, retention, true)
+
 		return nil
 	},
 }
@@ -118,8 +130,8 @@ var retentionDelete = &cobra.Command{
 			return fmt.Errorf("Retention ID cannot be zero")
 		}
 
-		if err := getAPIClient().DeleteRetention(retentionID); err != nil {
-			fmt.Fprint(os.Stderr, err, "\n")
+		if err := recordDelete(retentionID, getAPIClient().DeleteRetention(retentionID)); err != nil {
+			return err
 		}
 
 		return nil
@@ -138,13 +150,9 @@ var retentionInfo = &cobra.Command{
 		}
 
 		if retention, err := getAPIClient().GetRetention(retentionID); err != nil {
-			fmt.Fprint(os.Stderr, err, "\n")
+			return err
 		} else {
-			if getBoolFlag(cmd, "json") {
-				fmt.Println(retention.JSONString())
-			} else {
-				fmt.Println(retention)
-			}
+			printResource(cmd, retention, false)
 		}
 
 		return nil
@@ -188,9 +196,98 @@ func addCreateRetentionFlags(cmd *cobra.Command) {
 	cmd.Flags().String("name", "", "The retention policy name to show in the control panel")
 	cmd.MarkFlagRequired("name")
 
-	cmd.Flags().String("retention-type", "", "The retention type (bydays or bycount)")
+	cmd.Flags().String("retention-type", "", "The retention type (bydays, bycount or gfs)")
 	cmd.MarkFlagRequired("retention-type")
 
 	cmd.Flags().Int("count", 0, "The amount of backups to retain (either days or backups, depending on the retention type)")
-	cmd.MarkFlagRequired("count")
+
+	cmd.Flags().Int("keep-daily", 0, "GFS: the amount of daily backups to retain")
+	cmd.Flags().Int("keep-weekly", 0, "GFS: the amount of weekly backups to retain")
+	cmd.Flags().Int("keep-monthly", 0, "GFS: the amount of monthly backups to retain")
+	cmd.Flags().Int("keep-yearly", 0, "GFS: the amount of yearly backups to retain")
+
+	addRetentionConstraintFlags(cmd)
+}
+
+// setRetentionFlags copies the GFS keep counts and the constraints from the
+// flags into r. If onlyChanged is set, the flags not passed in the command
+// line are ignored
+func setRetentionFlags(cmd *cobra.Command, r *api.Retention, onlyChanged bool) error {
+	counts := map[string]*int{
+		"keep-daily":     &r.KeepDaily,
+		"keep-weekly":    &r.KeepWeekly,
+		"keep-monthly":   &r.KeepMonthly,
+		"keep-yearly":    &r.KeepYearly,
+		"min-keep":       &r.MinKeep,
+		"keep-last-full": &r.KeepLastFull,
+	}
+
+	for name, count := range counts {
+		if !onlyChanged || cmd.Flags().Changed(name) {
+			*count = getIntFlag(cmd, name)
+		}
+	}
+
+	if !onlyChanged || cmd.Flags().Changed("max-total-size") {
+		size, err := parseSize(getStringFlag(cmd, "max-total-size"))
+
+		if err != nil {
+			return err
+		}
+
+		r.MaxTotalSize = size
+	}
+
+	return nil
+}
+
+// addRetentionConstraintFlags adds the flags of the constraints any retention
+// type accepts
+func addRetentionConstraintFlags(cmd *cobra.Command) {
+	cmd.Flags().String("max-total-size", "", "Delete the oldest backups once they use more than this, for ex: 500GB (0 for no limit). "+
+		"Units are binary, 1GB is 1024 MB")
+	cmd.Flags().Int("min-keep", 0, "Never keep less than this amount of backups, even if older than the retention days")
+	cmd.Flags().Int("keep-last-full", 0, "Always keep this amount of the newest full backups")
+}
+
+// sizeUnits are the units parseSize accepts, in upper case. They are all
+// binary like the sizes the CLI prints, so 1GB is 1GiB
+var sizeUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+	"TB":  1 << 40,
+	"TIB": 1 << 40,
+	"PB":  1 << 50,
+	"PIB": 1 << 50,
+}
+
+// parseSize parses a size in bytes with an optional binary unit, for ex:
+// '1024', '500GB', '1.5 TiB'. An empty size is zero
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return 0, nil
+	}
+
+	number := strings.TrimRightFunc(s, func(r rune) bool { return unicode.IsLetter(r) || r == ' ' })
+	unit, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(s[len(number):]))]
+
+	if !ok {
+		return 0, fmt.Errorf("Unknown size unit in '%s', expected B, KB, MB, GB, TB or PB (or KiB, MiB, ...)", s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid size '%s', expected a positive number with an optional unit like 500GB", s)
+	}
+
+	return int64(value * unit), nil
 }
@@ -0,0 +1,277 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+var retentionSimulate = &cobra.Command{
+	Use:   "simulate",
+	Short: "Show which backups a retention policy would keep and delete, without deleting anything",
+	Long: "Show which backups a retention policy would keep and delete, without deleting anything.\n\n" +
+		"The policy is applied to the backup catalog of every job using it. Pass --retention-type, " +
+		"--count, the --keep-* flags or the constraint flags to simulate a change to the policy before updating it.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		retentionID := getIntFlag(cmd, "retention-id")
+
+		if retentionID == 0 {
+			return fmt.Errorf("Retention ID cannot be zero")
+		}
+
+		client := getAPIClient()
+		retention, err := client.GetRetention(retentionID)
+
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flags().Changed("retention-type") {
+			if retention.RetentionType, err = api.ParseRetentionType(getStringFlag(cmd, "retention-type")); err != nil {
+				return err
+			}
+		}
+
+		if cmd.Flags().Changed("count") {
+			retention.Count = getIntFlag(cmd, "count")
+		}
+
+		if err := setRetentionFlags(cmd, &retention, true); err != nil {
+			return err
+		}
+
+		if err := retention.Validate(); err != nil {
+			return err
+		}
+
+		jobs, err := client.ListJobs()
+
+		if err != nil {
+			return err
+		}
+
+		backups, err := client.ListBackups(api.BackupFilter{})
+
+		if err != nil {
+			return err
+		}
+
+		holds, err := client.ListHolds(0)
+
+		if err != nil {
+			return err
+		}
+
+		sim := simulateRetention(retention, jobs, backups, holds, time.Now())
+
+		if err := recordValue(sim, false); err != nil {
+			return err
+		}
+
+		if getBoolFlag(cmd, "json") {
+			bs, err := json.Marshal(sim)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(bs))
+		} else {
+			sim.write(os.Stdout)
+		}
+
+		return nil
+	},
+}
+
+// retentionSimulation is the outcome of applying a retention to the catalog
+type retentionSimulation struct {
+	Retention             api.Retention `json:"retention"`
+	Jobs                  []jobPruning  `json:"jobs"`
+	FreedBytes            int64         `json:"freedBytes"`
+	ServersWithoutBackups []int         `json:"serversWithoutBackups"`
+	HeldBackups           []int         `json:"heldBackups"`
+}
+
+type jobPruning struct {
+	Job   api.BackupJob `json:"job"`
+	Keep  []api.Backup  `json:"keep"`
+	Prune []api.Backup  `json:"prune"`
+	Freed int64         `json:"freedBytes"`
+}
+
+// simulateRetention prunes the backups of every job using the retention.
+// A job's backups are the ones with its server and storage, so jobs sharing
+// both share their backups, and these are only counted once
+func simulateRetention(r api.Retention, jobs []api.BackupJob, backups []api.Backup,
+	holds []api.BackupHold, now time.Time) retentionSimulation {
+
+	type serverStorage struct{ server, storage int }
+
+	byJob := map[serverStorage][]api.Backup{}
+	remaining := map[int]int{}
+
+	for _, b := range backups {
+		key := serverStorage{b.ServerID, b.StorageID}
+		byJob[key] = append(byJob[key], b)
+
+		if b.Succeeded() {
+			remaining[b.ServerID]++
+		}
+	}
+
+	sim := retentionSimulation{Retention: r}
+
+	for _, h := range holds {
+		if h.Active(now) {
+			sim.HeldBackups = append(sim.HeldBackups, h.BackupID)
+		}
+	}
+
+	hadBackups := map[int]bool{}
+	pruned := map[serverStorage]bool{}
+
+	for _, j := range jobs {
+		if j.RetentionID != r.ID {
+			continue
+		}
+
+		key := serverStorage{j.ServerID, j.StorageID}
+		p := jobPruning{Job: j}
+		p.Keep, p.Prune = r.Prune(byJob[key], now, holds...)
+
+		for _, b := range p.Prune {
+			p.Freed += b.Size
+		}
+
+		if len(p.Keep)+len(p.Prune) > 0 {
+			hadBackups[j.ServerID] = true
+		}
+
+		if !pruned[key] {
+			pruned[key] = true
+			sim.FreedBytes += p.Freed
+			remaining[j.ServerID] -= len(p.Prune)
+		}
+
+		sim.Jobs = append(sim.Jobs, p)
+	}
+
+	for server := range hadBackups {
+		if remaining[server] <= 0 {
+			sim.ServersWithoutBackups = append(sim.ServersWithoutBackups, server)
+		}
+	}
+
+	sort.Ints(sim.ServersWithoutBackups)
+
+	return sim
+}
+
+func (sim retentionSimulation) write(out io.Writer) {
+	fmt.Fprintf(out, "Simulating retention %q (%s)\n", sim.Retention.Name, sim.Retention.RetentionType)
+
+	if len(sim.Jobs) == 0 {
+		fmt.Fprintln(out, "No jobs use this retention policy")
+		return
+	}
+
+	kept, pruned := 0, 0
+	held := map[int]bool{}
+
+	for _, id := range sim.HeldBackups {
+		held[id] = true
+	}
+
+	for _, p := range sim.Jobs {
+		fmt.Fprintf(out, "\nJob %d %q (server %d, storage %d): keep %d, delete %d, frees %s\n",
+			p.Job.ID, p.Job.Name, p.Job.ServerID, p.Job.StorageID, len(p.Keep), len(p.Prune), formatSize(p.Freed))
+
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "BACKUP\tSTARTED\tTYPE\tSIZE\tACTION")
+
+		for _, b := range mergeBackups(p.Keep, p.Prune) {
+			action := "keep"
+
+			if b.pruned {
+				action = "DELETE"
+			} else if held[b.ID] {
+				action = "keep (held)"
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", b.ID, b.StartedAt.Format("2006-01-02 15:04"),
+				b.BackupType, formatSize(b.Size), action)
+		}
+
+		w.Flush()
+
+		kept += len(p.Keep)
+		pruned += len(p.Prune)
+	}
+
+	fmt.Fprintf(out, "\nTotal: keep %d, delete %d, frees %s\n", kept, pruned, formatSize(sim.FreedBytes))
+
+	for _, server := range sim.ServersWithoutBackups {
+		fmt.Fprintf(out, "WARNING: server %d would be left with zero backups\n", server)
+	}
+}
+
+type simulatedBackup struct {
+	api.Backup
+	pruned bool
+}
+
+// mergeBackups interleaves the kept and pruned backups oldest first
+func mergeBackups(keep, prune []api.Backup) []simulatedBackup {
+	all := make([]simulatedBackup, 0, len(keep)+len(prune))
+
+	for _, b := range keep {
+		all = append(all, simulatedBackup{b, false})
+	}
+
+	for _, b := range prune {
+		all = append(all, simulatedBackup{b, true})
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].StartedAt.Before(all[j].StartedAt.Time) })
+
+	return all
+}
+
+func init() {
+	retentionCmd.AddCommand(retentionSimulate)
+
+	retentionSimulate.Flags().Bool("json", false, "Output the simulation in JSON format")
+	retentionSimulate.Flags().Int("retention-id", 0, "Retention ID")
+	retentionSimulate.MarkFlagRequired("retention-id")
+
+	retentionSimulate.Flags().String("retention-type", "", "Simulate with this retention type instead (bydays, bycount or gfs)")
+	retentionSimulate.Flags().Int("count", 0, "Simulate with this count instead (either days or backups, depending on the retention type)")
+	retentionSimulate.Flags().Int("keep-daily", 0, "GFS: simulate with this amount of daily backups instead")
+	retentionSimulate.Flags().Int("keep-weekly", 0, "GFS: simulate with this amount of weekly backups instead")
+	retentionSimulate.Flags().Int("keep-monthly", 0, "GFS: simulate with this amount of monthly backups instead")
+	retentionSimulate.Flags().Int("keep-yearly", 0, "GFS: simulate with this amount of yearly backups instead")
+
+	addRetentionConstraintFlags(retentionSimulate)
+}
@@ -23,22 +23,37 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"net/http"
 	"strings"
+	"sync"
 )
 
 var cfgFile string
 var verbose bool
+var debugHTTP bool
+var recordDir, replayDir string
+
+// the record and replay transports keep state across requests, so every API
+// client of the process must share the same one
+var fixtureOnce sync.Once
+var fixtureTransport http.RoundTripper
+var fixtureErr error
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
-	Use:   "cloudbackup-cli",
-	Short: "command-line tool to interact with Binlogic CloudBackup [ https://www.binlogic.io/ ]",
+	Use:              "cloudbackup-cli",
+	Short:            "command-line tool to interact with Binlogic CloudBackup [ https://www.binlogic.io/ ]",
+	PersistentPreRun: startMachineMode,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	cmd, err := RootCmd.ExecuteC()
+
+	emitMachineResult(cmd, err)
+
+	if err != nil {
 		// fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -53,6 +68,14 @@ func init() {
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file in toml format (default is $HOME/.cloudbackup-cli.toml)")
 
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Print more verbose logging")
+	RootCmd.PersistentFlags().BoolVar(&debugHTTP, "debug-http", false, "Print every API request and response, "+
+		"with the signature headers and secrets redacted")
+	RootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Save every API request and response "+
+		"to this directory, with the secrets redacted")
+	RootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Serve the API responses saved "+
+		"with --record in this directory, without connecting to the API")
+	RootCmd.PersistentFlags().BoolVar(&machine, "machine", false, "Print a JSON line with the result of the command, "+
+		"for configuration management tools")
 
 	addPersistentString("access-key", "", "API access key", RootCmd)
 	addPersistentString("secret-key", "", "API secret key", RootCmd)
@@ -91,18 +114,67 @@ func initConfig() {
 	}
 }
 
-func getAPIClient() *api.Client {
-	var err error
-
+// newAPIClient creates the API client from the config, flags and env
+func newAPIClient() (*api.Client, error) {
 	accessKey := viper.GetString("access-key")
 	secretKey := viper.GetString("secret-key")
 	host := viper.GetString("host")
 
-	apiClient, err := api.NewAPIClient(host, accessKey, secretKey)
+	var options []api.Option
+
+	if recordDir != "" && replayDir != "" {
+		return nil, fmt.Errorf("--record and --replay cannot be used together")
+	}
+
+	fixtureOnce.Do(func() {
+		switch {
+		case recordDir != "":
+			fixtureTransport, fixtureErr = api.NewRecordTransport(recordDir, nil)
+
+		case replayDir != "":
+			fixtureTransport, fixtureErr = api.NewReplayTransport(replayDir)
+		}
+	})
+
+	if fixtureErr != nil {
+		return nil, fixtureErr
+	}
+
+	if fixtureTransport != nil {
+		options = append(options, api.WithTransport(fixtureTransport))
+	}
+
+	if replayDir != "" {
+		// the fixtures are matched without the host and signature, so the
+		// credentials are not needed to replay them
+		if host == "" {
+			host = "https://replay.invalid"
+		}
+
+		if accessKey == "" {
+			accessKey = "replay"
+		}
+
+		if secretKey == "" {
+			secretKey = "replay"
+		}
+	}
+
+	if debugHTTP {
+		options = append(options, api.WithLogger(api.NewTextLogger(os.Stderr, api.LOG_DEBUG)), api.WithDumpHTTP())
+	} else if verbose {
+		options = append(options, api.WithLogger(api.NewTextLogger(os.Stderr, api.LOG_INFO)))
+	}
+
+	return api.NewAPIClient(host, accessKey, secretKey, options...)
+}
+
+func getAPIClient() *api.Client {
+	apiClient, err := newAPIClient()
 
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		exitMachine(executingCmd, 1, err)
 	}
 
 	return apiClient
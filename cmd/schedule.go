@@ -14,10 +14,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/binlogicinc/cloudbackup-cli/api"
 	"github.com/spf13/cobra"
-	"os"
+	"time"
 )
 
 var scheduleCmd = &cobra.Command{
@@ -33,6 +34,8 @@ var scheduleNew = &cobra.Command{
 		name := getStringFlag(cmd, "name")
 		hours := getStringFlag(cmd, "hours")
 		days := getStringFlag(cmd, "days")
+		cron := getStringFlag(cmd, "cron")
+		timezone := getStringFlag(cmd, "timezone")
 
 		sType := getStringFlag(cmd, "schedule-type")
 		scheduleType, err := api.ParseScheduleType(sType)
@@ -41,19 +44,14 @@ var scheduleNew = &cobra.Command{
 			return err
 		}
 
-		schedule, err := getAPIClient().CreateSchedule(name, scheduleType, hours, days)
+		schedule, err := getAPIClient().CreateSchedule(name, scheduleType, hours, days, cron, timezone)
 
 		if err != nil {
 			return err
 		}
 
 		printVerbose("Schedule created successfully")
-
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(schedule.JSONString())
-		} else {
-			fmt.Println(schedule)
-		}
+		printResource(cmd, schedule, true)
 
 		return nil
 	},
@@ -76,19 +74,29 @@ var scheduleUpdate = &cobra.Command{
 			return err
 		}
 
-		if flag := cmd.Flag("name"); flag != nil {
+		before := snapshot(schedule)
+
+		if flag := cmd.Flag("name"); flag.Changed {
 			schedule.Name = flag.Value.String()
 		}
 
-		if flag := cmd.Flag("days"); flag != nil {
+		if flag := cmd.Flag("days"); flag.Changed {
 			schedule.ScheduleDays = getStringFlag(cmd, "days")
 		}
 
-		if flag := cmd.Flag("hours"); flag != nil {
+		if flag := cmd.Flag("hours"); flag.Changed {
 			schedule.ScheduleHours = getStringFlag(cmd, "hours")
 		}
 
-		if flag := cmd.Flag("schedule-type"); flag != nil {
+		if cmd.Flags().Changed("cron") {
+			schedule.CronExpression = getStringFlag(cmd, "cron")
+		}
+
+		if cmd.Flags().Changed("timezone") {
+			schedule.Timezone = getStringFlag(cmd, "timezone")
+		}
+
+		if flag := cmd.Flag("schedule-type"); flag.Changed {
 			newScheduleType, err := api.ParseScheduleType(flag.Value.String())
 
 			if err != nil {
@@ -98,15 +106,18 @@ var scheduleUpdate = &cobra.Command{
 			schedule.ScheduleType = newScheduleType
 		}
 
+		if unchanged(before, schedule) {
+			printVerbose("Schedule is up to date")
+			printResource(cmd, schedule, false)
+
+			return nil
+		}
+
 		if err := getAPIClient().UpdateSchedule(schedule); err != nil {
 			return err
 		}
 
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(schedule.JSONString())
-		} else {
-			fmt.Println(schedule)
-		}
+		printResource(cmd, schedule, true)
 
 		return nil
 	},
@@ -123,8 +134,8 @@ var scheduleDelete = &cobra.Command{
 			return fmt.Errorf("Schedule ID cannot be zero")
 		}
 
-		if err := getAPIClient().DeleteSchedule(scheduleID); err != nil {
-			fmt.Fprint(os.Stderr, err, "\n")
+		if err := recordDelete(scheduleID, getAPIClient().DeleteSchedule(scheduleID)); err != nil {
+			return err
 		}
 
 		return nil
@@ -143,25 +154,130 @@ var scheduleInfo = &cobra.Command{
 		}
 
 		if schedule, err := getAPIClient().GetSchedule(scheduleID); err != nil {
-			fmt.Fprint(os.Stderr, err, "\n")
+			return err
 		} else {
-			if getBoolFlag(cmd, "json") {
-				fmt.Println(schedule.JSONString())
-			} else {
-				fmt.Println(schedule)
+			printResource(cmd, schedule, false)
+		}
+
+		return nil
+	},
+}
+
+var scheduleNext = &cobra.Command{
+	Use:   "next",
+	Short: "Compute the next run times of a schedule in Binlogic CloudBackup",
+	Long: "Compute the next run times of a schedule in Binlogic CloudBackup.\n\n" +
+		"Times are computed locally from the schedule type, hours and days, in the schedule " +
+		"timezone (UTC if not set), and printed in the --tz timezone if given. Hourly schedules " +
+		"run every N hours starting at 00:00. Runs inside the schedule blackouts, and the " +
+		"--server-id blackouts if given, are skipped.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scheduleID := getIntFlag(cmd, "schedule-id")
+
+		if scheduleID == 0 {
+			return fmt.Errorf("Schedule ID cannot be zero")
+		}
+
+		count := getIntFlag(cmd, "count")
+
+		if count <= 0 {
+			return fmt.Errorf("Count must be > 0")
+		}
+
+		client := getAPIClient()
+		schedule, err := client.GetSchedule(scheduleID)
+
+		if err != nil {
+			return err
+		}
+
+		blackouts, err := getBlackouts(client, scheduleID, getIntFlag(cmd, "server-id"))
+
+		if err != nil {
+			return err
+		}
+
+		loc, err := schedule.Location()
+
+		if err != nil {
+			return err
+		}
+
+		if tz := getStringFlag(cmd, "tz"); tz != "" {
+			if loc, err = time.LoadLocation(tz); err != nil {
+				return fmt.Errorf("Unknown timezone '%s', expected an IANA name like 'America/New_York'", tz)
+			}
+		}
+
+		from := time.Now()
+
+		if f := getStringFlag(cmd, "from"); f != "" {
+			if from, err = parseTimeFlag(f, loc); err != nil {
+				return err
+			}
+		}
+
+		runs, err := schedule.NextRuns(from, count, blackouts...)
+
+		if err != nil {
+			return err
+		}
+
+		if err := recordValue(runs, false); err != nil {
+			return err
+		}
+
+		if getBoolFlag(cmd, "json") {
+			formatted := make([]string, len(runs))
+
+			for i, r := range runs {
+				formatted[i] = r.In(loc).Format(time.RFC3339)
 			}
+
+			bs, _ := json.Marshal(formatted)
+			fmt.Println(string(bs))
+
+			return nil
+		}
+
+		if len(runs) == 0 {
+			fmt.Printf("Schedule %s (%s) has no upcoming runs\n", schedule.Name, schedule.ScheduleType)
+			return nil
+		}
+
+		for _, r := range runs {
+			fmt.Println(r.In(loc).Format("Mon 2006-01-02 15:04 MST"))
 		}
 
 		return nil
 	},
 }
 
+// parseTimeFlag parses a time given in the command line, in RFC 3339 format
+// or as a date with optional HH:MM in the given location
+func parseTimeFlag(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid time '%s', expected RFC 3339, 'YYYY-MM-DD HH:MM' "+
+		"or 'YYYY-MM-DD' format", s)
+}
+
 func init() {
 	RootCmd.AddCommand(scheduleCmd)
 	scheduleCmd.AddCommand(scheduleNew)
 	scheduleCmd.AddCommand(scheduleUpdate)
 	scheduleCmd.AddCommand(scheduleDelete)
 	scheduleCmd.AddCommand(scheduleInfo)
+	scheduleCmd.AddCommand(scheduleNext)
 
 	// Here you will define your flags and configuration settings.
 
@@ -176,6 +292,16 @@ func init() {
 	scheduleDelete.Flags().Int("schedule-id", 0, "Schedule ID")
 	scheduleDelete.MarkFlagRequired("schedule-id")
 
+	scheduleNext.Flags().Int("schedule-id", 0, "Schedule ID")
+	scheduleNext.MarkFlagRequired("schedule-id")
+	scheduleNext.Flags().Int("count", 10, "How many run times to compute")
+	scheduleNext.Flags().String("from", "", "Compute runs after this time (RFC 3339, 'YYYY-MM-DD HH:MM' "+
+		"or 'YYYY-MM-DD'), now if not set")
+	scheduleNext.Flags().String("tz", "", "The IANA timezone to print the times in (and to read --from in), "+
+		"the schedule timezone if not set")
+	scheduleNext.Flags().Bool("json", false, "Output times in JSON format")
+	scheduleNext.Flags().Int("server-id", 0, "Also honour the blackouts of this server ID")
+
 	addCreateScheduleFlags(scheduleNew)
 
 	addCreateScheduleFlags(scheduleUpdate)
@@ -193,7 +319,7 @@ func addCreateScheduleFlags(cmd *cobra.Command) {
 	cmd.Flags().String("name", "", "The schedule name to show in the control panel")
 	cmd.MarkFlagRequired("name")
 
-	cmd.Flags().String("schedule-type", "", "The schedule type (ondemand, hourly, daily, weekly or monthly)")
+	cmd.Flags().String("schedule-type", "", "The schedule type (ondemand, hourly, daily, weekly, monthly or cron)")
 	cmd.MarkFlagRequired("schedule-type")
 
 	cmd.Flags().String("hours", "", "For hourly schedule, every how many hours it should run."+
@@ -201,4 +327,10 @@ func addCreateScheduleFlags(cmd *cobra.Command) {
 
 	cmd.Flags().String("days", "", "For weekly schedule, which days of the week to run (comma "+
 		"separated, starting with 0 being Sunday). For monthly, which day of the month to run.")
+
+	cmd.Flags().String("cron", "", "For cron schedule, a 5 field cron expression: minute hour "+
+		"day-of-month month day-of-week (for ex: '30 2 * * 1-5')")
+
+	cmd.Flags().String("timezone", "", "The IANA timezone the schedule times are in (for ex: "+
+		"'America/New_York'), UTC if not set")
 }
@@ -0,0 +1,448 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+var scheduleAnalyze = &cobra.Command{
+	Use:   "analyze",
+	Short: "Find overlapping backup windows across servers in Binlogic CloudBackup",
+	Long: "Find overlapping backup windows across servers in Binlogic CloudBackup.\n\n" +
+		"Every enabled backup job is placed in a timeline using the runs of its schedule and " +
+		"the median duration of its recent backups. Periods where more backups than " +
+		"--max-concurrent run at the same time are reported, along with staggered start " +
+		"times that would keep them under the threshold.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxConcurrent := getIntFlag(cmd, "max-concurrent")
+
+		if maxConcurrent <= 0 {
+			return fmt.Errorf("Max concurrent must be > 0")
+		}
+
+		days := getIntFlag(cmd, "days")
+
+		if days <= 0 {
+			return fmt.Errorf("Days must be > 0")
+		}
+
+		defaultDuration, err := time.ParseDuration(getStringFlag(cmd, "default-duration"))
+
+		if err != nil || defaultDuration <= 0 {
+			return fmt.Errorf("Invalid default duration '%s' (for ex: '45m')", getStringFlag(cmd, "default-duration"))
+		}
+
+		client := getAPIClient()
+
+		schedules, err := client.ListSchedules()
+
+		if err != nil {
+			return err
+		}
+
+		jobs, err := client.ListJobs()
+
+		if err != nil {
+			return err
+		}
+
+		backups, err := client.ListBackups(api.BackupFilter{})
+
+		if err != nil {
+			return err
+		}
+
+		blackouts, err := client.ListBlackouts(api.BlackoutFilter{})
+
+		if err != nil {
+			return err
+		}
+
+		from := time.Now().Truncate(time.Minute)
+		windows := backupWindows(schedules, jobs, backups, blackouts, from, from.AddDate(0, 0, days),
+			defaultDuration)
+
+		printBackupWindows(windows)
+
+		overloads := findOverloads(windows, maxConcurrent)
+
+		if len(overloads) == 0 {
+			fmt.Printf("\nNo more than %d backups run at the same time in the next %d days\n", maxConcurrent, days)
+			return nil
+		}
+
+		printOverloads(overloads, maxConcurrent)
+		printStaggerSuggestions(overloads, maxConcurrent)
+
+		return nil
+	},
+}
+
+// backupWindow is an expected run of a backup job
+type backupWindow struct {
+	job      api.BackupJob
+	schedule api.Schedule
+	start    time.Time
+	end      time.Time
+	measured bool // if the duration comes from the job backup history
+}
+
+func (w backupWindow) label() string {
+	name := w.job.Name
+
+	if name == "" {
+		name = fmt.Sprintf("job %d", w.job.ID)
+	}
+
+	return fmt.Sprintf("%s (server %d)", name, w.job.ServerID)
+}
+
+// jobDurations returns the median duration of the last 10 backups of each job
+func jobDurations(jobs []api.BackupJob, backups []api.Backup) map[int]time.Duration {
+	type serverStorage struct{ server, storage int }
+
+	history := map[serverStorage][]api.Backup{}
+
+	for _, b := range backups {
+		if b.Duration() > 0 {
+			key := serverStorage{b.ServerID, b.StorageID}
+			history[key] = append(history[key], b)
+		}
+	}
+
+	durations := map[int]time.Duration{}
+
+	for _, j := range jobs {
+		runs := history[serverStorage{j.ServerID, j.StorageID}]
+
+		if len(runs) == 0 {
+			continue
+		}
+
+		sort.Slice(runs, func(a, b int) bool { return runs[a].StartedAt.Before(runs[b].StartedAt.Time) })
+
+		if len(runs) > 10 {
+			runs = runs[len(runs)-10:]
+		}
+
+		ds := make([]time.Duration, len(runs))
+
+		for i, r := range runs {
+			ds[i] = r.Duration()
+		}
+
+		sort.Slice(ds, func(a, b int) bool { return ds[a] < ds[b] })
+		durations[j.ID] = ds[len(ds)/2]
+	}
+
+	return durations
+}
+
+// backupWindows places every enabled job in the [from, to) timeline, skipping
+// the runs inside the blackouts of its schedule or server. Jobs whose schedule
+// can't be computed are left out with a warning, so one invalid schedule
+// doesn't prevent analyzing the rest
+func backupWindows(schedules []api.Schedule, jobs []api.BackupJob, backups []api.Backup,
+	blackouts []api.Blackout, from, to time.Time, defaultDuration time.Duration) []backupWindow {
+
+	byID := map[int]api.Schedule{}
+
+	for _, s := range schedules {
+		byID[s.ID] = s
+	}
+
+	durations := jobDurations(jobs, backups)
+
+	var windows []backupWindow
+	invalid := map[int]bool{}
+
+	for _, j := range jobs {
+		schedule, ok := byID[j.ScheduleID]
+
+		if !j.Enabled || !ok || invalid[schedule.ID] {
+			continue
+		}
+
+		var jobBlackouts []api.Blackout
+
+		for _, b := range blackouts {
+			if b.ScheduleID == schedule.ID || b.ServerID == j.ServerID {
+				jobBlackouts = append(jobBlackouts, b)
+			}
+		}
+
+		runs, err := schedule.RunsBetween(from, to, jobBlackouts...)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: skipping schedule %d %q, %s\n", schedule.ID, schedule.Name, err)
+			invalid[schedule.ID] = true
+			continue
+		}
+
+		duration, measured := durations[j.ID]
+
+		if !measured {
+			duration = defaultDuration
+		}
+
+		for _, r := range runs {
+			windows = append(windows, backupWindow{j, schedule, r, r.Add(duration), measured})
+		}
+	}
+
+	sort.Slice(windows, func(a, b int) bool {
+		if !windows[a].start.Equal(windows[b].start) {
+			return windows[a].start.Before(windows[b].start)
+		}
+
+		return windows[a].job.ID < windows[b].job.ID
+	})
+
+	return windows
+}
+
+// overload is a period where more than the allowed backups run at once
+type overload struct {
+	start   time.Time
+	end     time.Time
+	peak    int
+	windows []backupWindow
+}
+
+// findOverloads sweeps the sorted windows and returns the periods where more
+// than max of them overlap
+func findOverloads(windows []backupWindow, max int) []overload {
+	type event struct {
+		at    time.Time
+		start bool
+		idx   int
+	}
+
+	var events []event
+
+	for i, w := range windows {
+		events = append(events, event{w.start, true, i}, event{w.end, false, i})
+	}
+
+	// ends go before starts at the same instant, back to back runs do not overlap
+	sort.Slice(events, func(a, b int) bool {
+		if !events[a].at.Equal(events[b].at) {
+			return events[a].at.Before(events[b].at)
+		}
+
+		return !events[a].start && events[b].start
+	})
+
+	var overloads []overload
+	var current *overload
+
+	active := map[int]bool{}
+
+	for _, e := range events {
+		if e.start {
+			active[e.idx] = true
+		} else {
+			delete(active, e.idx)
+		}
+
+		switch {
+		case len(active) > max && current == nil:
+			current = &overload{start: e.at}
+			fallthrough
+
+		case len(active) > max:
+			if len(active) > current.peak {
+				current.peak = len(active)
+			}
+
+			for idx := range active {
+				if !containsWindow(current.windows, windows[idx]) {
+					current.windows = append(current.windows, windows[idx])
+				}
+			}
+
+		case current != nil:
+			current.end = e.at
+			overloads = append(overloads, *current)
+			current = nil
+		}
+	}
+
+	for i := range overloads {
+		ws := overloads[i].windows
+
+		sort.Slice(ws, func(a, b int) bool {
+			if !ws[a].start.Equal(ws[b].start) {
+				return ws[a].start.Before(ws[b].start)
+			}
+
+			return ws[a].job.ID < ws[b].job.ID
+		})
+	}
+
+	return overloads
+}
+
+func containsWindow(ws []backupWindow, w backupWindow) bool {
+	for _, x := range ws {
+		if x.job.ID == w.job.ID && x.start.Equal(w.start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staggerSuggestion is a new start time for a job to avoid an overload
+type staggerSuggestion struct {
+	window backupWindow
+	start  time.Time
+}
+
+// staggerStarts assigns the windows of an overload to max lanes, each backup
+// starting when the earliest lane frees up, rounded up to 5 minutes
+func staggerStarts(windows []backupWindow, max int) []staggerSuggestion {
+	lanes := make([]time.Time, max)
+	var suggestions []staggerSuggestion
+
+	for _, w := range windows {
+		lane := 0
+
+		for i := range lanes {
+			if lanes[i].Before(lanes[lane]) {
+				lane = i
+			}
+		}
+
+		start := w.start
+
+		if lanes[lane].After(start) {
+			start = lanes[lane]
+
+			if rem := start.Sub(w.start) % (5 * time.Minute); rem > 0 {
+				start = start.Add(5*time.Minute - rem)
+			}
+		}
+
+		lanes[lane] = start.Add(w.end.Sub(w.start))
+
+		if !start.Equal(w.start) {
+			suggestions = append(suggestions, staggerSuggestion{w, start})
+		}
+	}
+
+	return suggestions
+}
+
+func printBackupWindows(windows []backupWindow) {
+	type jobSummary struct {
+		window backupWindow
+		runs   int
+	}
+
+	var order []int
+	summaries := map[int]*jobSummary{}
+
+	for _, w := range windows {
+		if s, ok := summaries[w.job.ID]; ok {
+			s.runs++
+			continue
+		}
+
+		summaries[w.job.ID] = &jobSummary{w, 1}
+		order = append(order, w.job.ID)
+	}
+
+	sort.Ints(order)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSCHEDULE\tRUNS\tEXPECTED DURATION\tNEXT START")
+
+	for _, id := range order {
+		s := summaries[id]
+		duration := s.window.end.Sub(s.window.start).String()
+
+		if !s.window.measured {
+			duration += " (default)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.window.label(), s.window.schedule.Name, s.runs,
+			duration, s.window.start.Format("Mon 2006-01-02 15:04 MST"))
+	}
+
+	w.Flush()
+}
+
+func printOverloads(overloads []overload, max int) {
+	fmt.Printf("\n%d periods with more than %d concurrent backups:\n", len(overloads), max)
+
+	for _, o := range overloads {
+		labels := make([]string, len(o.windows))
+
+		for i, w := range o.windows {
+			labels[i] = w.label()
+		}
+
+		fmt.Printf("  %s - %s: %d concurrent (%s)\n", o.start.Format("Mon 2006-01-02 15:04 MST"),
+			o.end.Format("15:04"), o.peak, strings.Join(labels, ", "))
+	}
+}
+
+// printStaggerSuggestions prints new start times for the jobs involved in
+// overloads, once per job and schedule time of day
+func printStaggerSuggestions(overloads []overload, max int) {
+	type key struct {
+		job       int
+		timeOfDay string
+	}
+
+	seen := map[key]bool{}
+
+	fmt.Println("\nSuggested staggered start times:")
+
+	for _, o := range overloads {
+		for _, s := range staggerStarts(o.windows, max) {
+			k := key{s.window.job.ID, s.window.start.Format("15:04")}
+
+			if seen[k] {
+				continue
+			}
+
+			seen[k] = true
+
+			fmt.Printf("  %s: move from %s to %s (schedule %s)\n", s.window.label(),
+				s.window.start.Format("15:04"), s.start.Format("15:04"), s.window.schedule.Name)
+		}
+	}
+
+	fmt.Println("\nJobs sharing a schedule need a schedule of their own to start at a different time.")
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleAnalyze)
+
+	scheduleAnalyze.Flags().Int("max-concurrent", 2, "The maximum number of backups that should run at the same time")
+	scheduleAnalyze.Flags().Int("days", 7, "How many days ahead to analyze")
+	scheduleAnalyze.Flags().String("default-duration", "30m", "The expected duration for jobs without backup history")
+}
@@ -0,0 +1,188 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+var scheduleBlackout = &cobra.Command{
+	Use:   "blackout",
+	Short: "Add, list and remove blackout windows where backups must not run",
+}
+
+var scheduleBlackoutAdd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a blackout window to a schedule or server in Binlogic CloudBackup",
+	Long: "Add a blackout window to a schedule or server in Binlogic CloudBackup.\n\n" +
+		"Either a date range (--from and --to, for ex: a quarterly freeze) or a weekly " +
+		"recurring window (--weekdays, --start and --end, for ex: peak traffic hours). " +
+		"Weekly windows whose end is before their start finish the next day.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		blackout := api.Blackout{
+			Reason:     getStringFlag(cmd, "reason"),
+			ScheduleID: getIntFlag(cmd, "schedule-id"),
+			ServerID:   getIntFlag(cmd, "server-id"),
+			Timezone:   getStringFlag(cmd, "timezone"),
+		}
+
+		from, to := getStringFlag(cmd, "from"), getStringFlag(cmd, "to")
+		weekdays := getStringFlag(cmd, "weekdays")
+
+		switch {
+		case from != "" || to != "":
+			if weekdays != "" {
+				return fmt.Errorf("A blackout is either a date range (--from, --to) or weekly (--weekdays), not both")
+			}
+
+			loc := time.UTC
+
+			if blackout.Timezone != "" {
+				var err error
+
+				if loc, err = time.LoadLocation(blackout.Timezone); err != nil {
+					return fmt.Errorf("Unknown timezone '%s', expected an IANA name like 'America/New_York'",
+						blackout.Timezone)
+				}
+			}
+
+			start, err := parseTimeFlag(from, loc)
+
+			if err != nil {
+				return err
+			}
+
+			end, err := parseTimeFlag(to, loc)
+
+			if err != nil {
+				return err
+			}
+
+			blackout.BlackoutType = api.BLACKOUT_DATE_RANGE
+			blackout.Start = api.Timestamp{Time: start}
+			blackout.End = api.Timestamp{Time: end}
+
+		case weekdays != "":
+			blackout.BlackoutType = api.BLACKOUT_WEEKLY
+			blackout.Weekdays = weekdays
+			blackout.StartTime = getStringFlag(cmd, "start")
+			blackout.EndTime = getStringFlag(cmd, "end")
+
+		default:
+			return fmt.Errorf("Either --from and --to or --weekdays, --start and --end must be set")
+		}
+
+		blackout, err := getAPIClient().CreateBlackout(blackout)
+
+		if err != nil {
+			return err
+		}
+
+		printVerbose("Blackout created successfully")
+		printResource(cmd, blackout, true)
+
+		return nil
+	},
+}
+
+var scheduleBlackoutList = &cobra.Command{
+	Use:     "list",
+	Short:   "List the blackout windows of a schedule or server in Binlogic CloudBackup",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		blackouts, err := getAPIClient().ListBlackouts(api.BlackoutFilter{
+			ScheduleID: getIntFlag(cmd, "schedule-id"),
+			ServerID:   getIntFlag(cmd, "server-id"),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for i, b := range blackouts {
+			if i > 0 && !getBoolFlag(cmd, "json") {
+				fmt.Println()
+			}
+
+			printResource(cmd, b, false)
+		}
+
+		return nil
+	},
+}
+
+var scheduleBlackoutRemove = &cobra.Command{
+	Use:     "remove",
+	Short:   "Remove a blackout window in Binlogic CloudBackup",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		blackoutID := getIntFlag(cmd, "blackout-id")
+
+		if blackoutID == 0 {
+			return fmt.Errorf("Blackout ID cannot be zero")
+		}
+
+		if err := recordDelete(blackoutID, getAPIClient().DeleteBlackout(blackoutID)); err != nil {
+			return err
+		}
+
+		printVerbose("Blackout removed successfully")
+
+		return nil
+	},
+}
+
+// getBlackouts returns the blackouts of the schedule and, if serverID is not
+// zero, the ones of the server too
+func getBlackouts(client api.ClientAPI, scheduleID, serverID int) ([]api.Blackout, error) {
+	blackouts, err := client.ListBlackouts(api.BlackoutFilter{ScheduleID: scheduleID})
+
+	if err != nil || serverID == 0 {
+		return blackouts, err
+	}
+
+	serverBlackouts, err := client.ListBlackouts(api.BlackoutFilter{ServerID: serverID})
+
+	return append(blackouts, serverBlackouts...), err
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleBlackout)
+	scheduleBlackout.AddCommand(scheduleBlackoutAdd)
+	scheduleBlackout.AddCommand(scheduleBlackoutList)
+	scheduleBlackout.AddCommand(scheduleBlackoutRemove)
+
+	scheduleBlackoutAdd.Flags().Bool("json", false, "Output info in JSON format")
+	scheduleBlackoutAdd.Flags().String("reason", "", "Why backups must not run, for ex: 'Q4 freeze'")
+	scheduleBlackoutAdd.Flags().Int("schedule-id", 0, "Attach the blackout to this schedule ID")
+	scheduleBlackoutAdd.Flags().Int("server-id", 0, "Attach the blackout to this server ID")
+	scheduleBlackoutAdd.Flags().String("from", "", "Date range start (RFC 3339, 'YYYY-MM-DD HH:MM' or 'YYYY-MM-DD')")
+	scheduleBlackoutAdd.Flags().String("to", "", "Date range end, exclusive (RFC 3339, 'YYYY-MM-DD HH:MM' or 'YYYY-MM-DD')")
+	scheduleBlackoutAdd.Flags().String("weekdays", "", "Weekly window days (comma separated, starting with 0 being Sunday)")
+	scheduleBlackoutAdd.Flags().String("start", "", "Weekly window start time (00:00 format)")
+	scheduleBlackoutAdd.Flags().String("end", "", "Weekly window end time (00:00 format)")
+	scheduleBlackoutAdd.Flags().String("timezone", "", "The IANA timezone of the blackout times, UTC if not set")
+
+	scheduleBlackoutList.Flags().Bool("json", false, "Output info in JSON format")
+	scheduleBlackoutList.Flags().Int("schedule-id", 0, "Only list blackouts of this schedule ID")
+	scheduleBlackoutList.Flags().Int("server-id", 0, "Only list blackouts of this server ID")
+
+	scheduleBlackoutRemove.Flags().Int("blackout-id", 0, "Blackout ID")
+	scheduleBlackoutRemove.MarkFlagRequired("blackout-id")
+}
@@ -0,0 +1,132 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// stdinSecretRead is set once a secret was read from stdin, as it can only
+// provide one
+var stdinSecretRead bool
+
+// addSecretFlag adds a flag for a secret along with the -file, -stdin and -env
+// variants, so the secret does not need to show up in ps or the shell history
+func addSecretFlag(cmd *cobra.Command, name, value, usage string) {
+	cmd.Flags().String(name, value, usage+" (use @path to read it from a file)")
+	cmd.Flags().String(name+"-file", "", "Read --"+name+" from this file")
+	cmd.Flags().Bool(name+"-stdin", false, "Read --"+name+" from stdin")
+	cmd.Flags().String(name+"-env", "", "Read --"+name+" from this environment variable")
+}
+
+// secretFlagChanged reports if the secret was passed in any of its variants
+func secretFlagChanged(cmd *cobra.Command, name string) bool {
+	for _, suffix := range []string{"", "-file", "-stdin", "-env"} {
+		if cmd.Flags().Changed(name + suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getSecretFlag reads a secret added with addSecretFlag from the variant
+// passed, stripping trailing newlines. A value starting with @ is read from
+// the file it names, use @@ for a literal @
+func getSecretFlag(cmd *cobra.Command, name string) (string, error) {
+	var sources []string
+
+	for _, suffix := range []string{"", "-file", "-stdin", "-env"} {
+		if cmd.Flags().Changed(name + suffix) {
+			sources = append(sources, "--"+name+suffix)
+		}
+	}
+
+	if len(sources) > 1 {
+		return "", fmt.Errorf("Only one of %s can be set", strings.Join(sources, ", "))
+	}
+
+	switch {
+	case cmd.Flags().Changed(name + "-file"):
+		return readSecretFile(getStringFlag(cmd, name+"-file"))
+
+	case getBoolFlag(cmd, name+"-stdin"):
+		if stdinSecretRead {
+			return "", fmt.Errorf("Only one secret can be read from stdin, use --%s-file or --%s-env", name, name)
+		}
+
+		stdinSecretRead = true
+		b, err := ioutil.ReadAll(os.Stdin)
+
+		if err != nil {
+			return "", fmt.Errorf("%s, while reading --%s from stdin", err, name)
+		}
+
+		return trimSecret(string(b)), nil
+
+	case cmd.Flags().Changed(name + "-env"):
+		env := getStringFlag(cmd, name+"-env")
+		value, ok := os.LookupEnv(env)
+
+		if !ok {
+			return "", fmt.Errorf("Environment variable %s for --%s is not set", env, name)
+		}
+
+		return trimSecret(value), nil
+	}
+
+	value := getStringFlag(cmd, name)
+
+	if strings.HasPrefix(value, "@@") {
+		return value[1:], nil
+	}
+
+	if strings.HasPrefix(value, "@") {
+		return readSecretFile(value[1:])
+	}
+
+	return value, nil
+}
+
+// readSecretFile reads a secret from a file that only its owner can access
+func readSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("Secret file %s can be accessed by other users (mode %s), "+
+			"restrict it with 'chmod 600 %s'", path, info.Mode().Perm(), path)
+	}
+
+	b, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return trimSecret(string(b)), nil
+}
+
+func trimSecret(s string) string {
+	return strings.TrimRight(s, "\r\n")
+}
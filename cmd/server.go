@@ -14,13 +14,9 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/binlogicinc/cloudbackup-cli/api"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
-	"os"
-	"os/exec"
 )
 
 // serverCmd represents the server command
@@ -40,7 +36,11 @@ var serverNew = &cobra.Command{
 		dbHost := getStringFlag(cmd, "db-host")
 		dbPort := getStringFlag(cmd, "db-port")
 		dbUser := getStringFlag(cmd, "db-user")
-		dbPass := getStringFlag(cmd, "db-pass")
+		dbPass, err := getSecretFlag(cmd, "db-pass")
+
+		if err != nil {
+			return err
+		}
 
 		databaseType, err := api.ParseDatabaseType(dbType)
 
@@ -55,12 +55,7 @@ var serverNew = &cobra.Command{
 		}
 
 		printVerbose("Server created successfully")
-
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(server.JSONString())
-		} else {
-			fmt.Println(server)
-		}
+		printResource(cmd, server, true)
 
 		return nil
 	},
@@ -83,31 +78,35 @@ var serverUpdate = &cobra.Command{
 			return err
 		}
 
-		if flag := cmd.Flag("name"); flag != nil {
+		before := snapshot(server)
+
+		if flag := cmd.Flag("name"); flag.Changed {
 			server.Name = flag.Value.String()
 		}
 
-		if flag := cmd.Flag("db-host"); flag != nil {
+		if flag := cmd.Flag("db-host"); flag.Changed {
 			server.DbHost = flag.Value.String()
 		}
 
-		if flag := cmd.Flag("db-port"); flag != nil {
+		if flag := cmd.Flag("db-port"); flag.Changed {
 			server.DbPort = flag.Value.String()
 		}
 
-		if flag := cmd.Flag("db-user"); flag != nil {
+		if flag := cmd.Flag("db-user"); flag.Changed {
 			server.DbUser = flag.Value.String()
 		}
 
-		if flag := cmd.Flag("db-pass"); flag != nil {
-			server.DbPass = flag.Value.String()
+		if secretFlagChanged(cmd, "db-pass") {
+			if server.DbPass, err = getSecretFlag(cmd, "db-pass"); err != nil {
+				return err
+			}
 		}
 
-		if flag := cmd.Flag("readonly"); flag != nil {
+		if flag := cmd.Flag("readonly"); flag.Changed {
 			server.Readonly = getBoolFlag(cmd, "readonly")
 		}
 
-		if flag := cmd.Flag("db-type"); flag != nil {
+		if flag := cmd.Flag("db-type"); flag.Changed {
 			newDbType, err := api.ParseDatabaseType(flag.Value.String())
 
 			if err != nil {
@@ -119,15 +118,18 @@ var serverUpdate = &cobra.Command{
 			}
 		}
 
+		if unchanged(before, server) {
+			printVerbose("Server is up to date")
+			printResource(cmd, server, false)
+
+			return nil
+		}
+
 		if err := getAPIClient().UpdateServer(server); err != nil {
 			return err
 		}
 
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(server.JSONString())
-		} else {
-			fmt.Println(server)
-		}
+		printResource(cmd, server, true)
 
 		return nil
 	},
@@ -144,7 +146,11 @@ var serverDelete = &cobra.Command{
 			return fmt.Errorf("Server ID cannot be zero")
 		}
 
-		return getAPIClient().DeleteServer(serverID)
+		if err := recordDelete(serverID, getAPIClient().DeleteServer(serverID)); err != nil {
+			return err
+		}
+
+		return nil
 	},
 }
 
@@ -162,11 +168,7 @@ var serverInfo = &cobra.Command{
 		if server, err := getAPIClient().GetServer(serverID); err != nil {
 			return err
 		} else {
-			if getBoolFlag(cmd, "json") {
-				fmt.Println(server.JSONString())
-			} else {
-				fmt.Println(server)
-			}
+			printResource(cmd, server, false)
 		}
 
 		return nil
@@ -174,8 +176,12 @@ var serverInfo = &cobra.Command{
 }
 
 var serverInstall = &cobra.Command{
-	Use:     "install",
-	Short:   "Install a server in this host or print the install script via stdout",
+	Use:   "install",
+	Short: "Install a server in this host or print the install script via stdout",
+	Long: "Install a server in this host or print the install script via stdout.\n\n" +
+		"The script checksum and signature are verified against the pinned Binlogic key before " +
+		"executing it. Tampered scripts are never executed, and unsigned ones only with " +
+		"--allow-unsigned. Use --inspect to review the commands the script will run.",
 	PreRunE: checkRequiredFlags,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		serverID := getIntFlag(cmd, "server-id")
@@ -184,28 +190,13 @@ var serverInstall = &cobra.Command{
 			return fmt.Errorf("Server ID cannot be zero")
 		}
 
-		if install, err := getAPIClient().GetServerInstall(serverID); err != nil {
+		script, err := getAPIClient().GetServerInstall(serverID)
+
+		if err != nil {
 			return err
-		} else {
-			if viper.GetBool("dry-run") {
-				fmt.Println(string(install))
-			} else {
-				if err := checkRoot(); err != nil {
-					return err
-				}
-
-				cmd := exec.Command("bash")
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				cmd.Stdin = bytes.NewReader(install)
-
-				if err := cmd.Run(); err != nil {
-					return err
-				}
-			}
 		}
 
-		return nil
+		return runScript(cmd, script)
 	},
 }
 
@@ -224,7 +215,7 @@ func init() {
 	// serverCmd.PersistentFlags().String("foo", "", "A help for foo")
 
 	serverInstall.Flags().Int("server-id", 0, "Server ID")
-	serverInstall.Flags().Bool("dry-run", false, "Output install script instead of executing it")
+	addScriptFlags(serverInstall, "install")
 
 	serverInfo.Flags().Int("server-id", 0, "Server ID")
 	serverInfo.Flags().Bool("json", false, "Output info in JSON format")
@@ -257,5 +248,5 @@ func addCreateServerFlags(cmd *cobra.Command) {
 	cmd.Flags().String("db-host", "localhost", "The host of the database to connect the agent to")
 	cmd.Flags().String("db-port", "", "The port of the database to connect the agent to")
 	cmd.Flags().String("db-user", "", "The user the agent will use to connect to the database")
-	cmd.Flags().String("db-pass", "", "The password the agent will use to connect to the database")
+	addSecretFlag(cmd, "db-pass", "", "The password the agent will use to connect to the database")
 }
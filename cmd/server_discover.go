@@ -0,0 +1,163 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/binlogicinc/cloudbackup-cli/discover"
+	"github.com/spf13/cobra"
+)
+
+var serverDiscover = &cobra.Command{
+	Use:   "discover",
+	Short: "Find the databases in this host and propose them as servers",
+	Long: "Find the databases in this host and propose them as servers.\n\n" +
+		"Scans the listening ports and unix sockets (when run as root, the ones of every user), and " +
+		"the default config files of MySQL, MariaDB, Percona Server, MongoDB and PostgreSQL. With " +
+		"--create the proposed servers are created, and with --install their agents installed too " +
+		"(the install script flags apply, see 'server install --help'). Servers whose name already " +
+		"exists are reported instead of created or installed again, so discover can be rerun.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instances, err := discover.Discover()
+
+		if err != nil {
+			return err
+		}
+
+		if len(instances) == 0 {
+			fmt.Fprintln(os.Stderr, "No databases found in this host")
+			return nil
+		}
+
+		hostname, _ := os.Hostname()
+		dbPass, err := getSecretFlag(cmd, "db-pass")
+
+		if err != nil {
+			return err
+		}
+
+		// the password is only sent to CreateServer, never printed
+		for i := range instances {
+			instances[i].Server.Name = instances[i].ProposedName(hostname)
+			instances[i].Server.DbUser = getStringFlag(cmd, "db-user")
+		}
+
+		create := getBoolFlag(cmd, "create") || getBoolFlag(cmd, "install")
+
+		if !create {
+			return printInstances(cmd, instances)
+		}
+
+		client := getAPIClient()
+		servers, err := client.ListServers()
+
+		if err != nil {
+			return err
+		}
+
+		existing := map[string]api.Server{}
+
+		for _, s := range servers {
+			existing[s.Name] = s
+		}
+
+		for _, instance := range instances {
+			s := instance.Server
+
+			if server, ok := existing[s.Name]; ok {
+				fmt.Fprintf(os.Stderr, "Server %s already exists with ID %d, skipping it\n", server.Name, server.ID)
+				printResource(cmd, server, false)
+
+				continue
+			}
+
+			dbType, err := api.ParseDatabaseType(instance.DbTypeName())
+
+			if err != nil {
+				return err
+			}
+
+			server, err := client.CreateServer(s.Name, dbType, getBoolFlag(cmd, "readonly"),
+				s.DbHost, s.DbPort, s.DbUser, dbPass)
+
+			if err != nil {
+				return fmt.Errorf("%s, while creating server %s", err, s.Name)
+			}
+
+			printVerbose("Server %s created successfully", server.Name)
+			printResource(cmd, server, true)
+
+			if !getBoolFlag(cmd, "install") {
+				continue
+			}
+
+			script, err := client.GetServerInstall(server.ID)
+
+			if err != nil {
+				return err
+			}
+
+			if err := runScript(cmd, script); err != nil {
+				return fmt.Errorf("%s, while installing server %s", err, server.Name)
+			}
+		}
+
+		return nil
+	},
+}
+
+func printInstances(cmd *cobra.Command, instances []discover.Instance) error {
+	for i, instance := range instances {
+		if err := recordValue(instance, false); err != nil {
+			return err
+		}
+
+		if getBoolFlag(cmd, "json") {
+			bs, err := json.Marshal(instance)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(bs))
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("Found %s\n", instance)
+		fmt.Printf("Proposed: server new --name %s --db-type %s --db-host %s --db-port %s\n",
+			instance.Server.Name, instance.DbTypeName(), instance.Server.DbHost, instance.Server.DbPort)
+	}
+
+	return nil
+}
+
+func init() {
+	serverCmd.AddCommand(serverDiscover)
+
+	serverDiscover.Flags().Bool("json", false, "Output info in JSON format")
+	serverDiscover.Flags().Bool("create", false, "Create the proposed servers")
+	serverDiscover.Flags().Bool("install", false, "Create the proposed servers and install their agents in this host")
+	serverDiscover.Flags().Bool("readonly", false, "Create the servers readonly (can be backed up but can't receive restores)")
+	serverDiscover.Flags().String("db-user", "", "The user the agents will use to connect to the databases")
+	addSecretFlag(serverDiscover, "db-pass", "", "The password the agents will use to connect to the databases")
+	addScriptFlags(serverDiscover, "install")
+}
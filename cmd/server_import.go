@@ -0,0 +1,477 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+var serverImport = &cobra.Command{
+	Use:   "import",
+	Short: "Create many servers from a CSV or JSON inventory file",
+	Long: "Create many servers from a CSV or JSON inventory file.\n\n" +
+		"CSV files need a header with the columns name, db_type, db_host, db_port and optionally " +
+		"db_user, db_pass, readonly, storage_id, schedule_id and retention_id. JSON files are an array " +
+		"of objects with the same fields in camel case (dbType, storageId, ...).\n\n" +
+		"Servers whose name already exists are not created again, so an import can be retried. Rows " +
+		"with a storage, schedule and retention (or the --storage-id, --schedule-id and --retention-id " +
+		"defaults) are linked with a backup job. Failed rows are reported and do not stop the import.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := getStringFlag(cmd, "file")
+		rows, err := readInventory(path, getStringFlag(cmd, "format"))
+
+		if err != nil {
+			return err
+		}
+
+		checkInventoryPermissions(path, rows)
+
+		defaults := inventoryRow{
+			StorageID:   getIntFlag(cmd, "storage-id"),
+			ScheduleID:  getIntFlag(cmd, "schedule-id"),
+			RetentionID: getIntFlag(cmd, "retention-id"),
+		}
+
+		client := getAPIClient()
+		results, err := importServers(client, rows, defaults, getIntFlag(cmd, "concurrency"))
+
+		if err != nil {
+			return err
+		}
+
+		for _, r := range results {
+			if err := recordValue(r, r.Server == "created" || r.Job == "created"); err != nil {
+				return err
+			}
+		}
+
+		if getBoolFlag(cmd, "json") {
+			for _, r := range results {
+				bs, _ := json.Marshal(r)
+				fmt.Println(string(bs))
+			}
+		} else {
+			printImportResults(os.Stdout, results)
+		}
+
+		failed := 0
+
+		for _, r := range results {
+			if r.Error != "" {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d rows failed", failed, len(results))
+		}
+
+		return nil
+	},
+}
+
+// inventoryRow is a server to import, with the IDs to link it to a job
+type inventoryRow struct {
+	line int
+	err  string // why the row could not be read, it is reported instead of imported
+
+	Name        string `json:"name"`
+	DbType      string `json:"dbType"`
+	DbHost      string `json:"dbHost"`
+	DbPort      string `json:"dbPort"`
+	DbUser      string `json:"dbUser"`
+	DbPass      string `json:"dbPass"`
+	Readonly    bool   `json:"readonly"`
+	StorageID   int    `json:"storageId"`
+	ScheduleID  int    `json:"scheduleId"`
+	RetentionID int    `json:"retentionId"`
+}
+
+type importResult struct {
+	Row      int    `json:"row"`
+	Name     string `json:"name"`
+	Server   string `json:"server"`
+	ServerID int    `json:"serverId,omitempty"`
+	Job      string `json:"job,omitempty"`
+	JobID    int    `json:"jobId,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// readInventory reads the rows of a CSV or JSON inventory. The format is
+// guessed from the file extension if not given
+func readInventory(path, format string) ([]inventoryRow, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	switch format {
+	case "csv":
+		return readInventoryCSV(f)
+
+	case "json":
+		var rows []inventoryRow
+
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("%s, while reading JSON inventory %s", err, path)
+		}
+
+		for i := range rows {
+			rows[i].line = i + 1
+		}
+
+		return rows, nil
+	}
+
+	return nil, fmt.Errorf("Unknown inventory format '%s', expected csv or json", format)
+}
+
+func readInventoryCSV(r io.Reader) ([]inventoryRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s, while reading CSV header", err)
+	}
+
+	columns := map[string]int{}
+
+	for i, name := range header {
+		columns[strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(name))] = i
+	}
+
+	for _, required := range []string{"name", "dbtype", "dbhost", "dbport"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV inventory has no %s column", required)
+		}
+	}
+
+	var rows []inventoryRow
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			return rows, nil
+		}
+
+		if perr, ok := err.(*csv.ParseError); ok {
+			rows = append(rows, inventoryRow{line: line, err: perr.Err.Error()})
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+
+			return ""
+		}
+
+		row := inventoryRow{
+			line:   line,
+			Name:   get("name"),
+			DbType: get("dbtype"),
+			DbHost: get("dbhost"),
+			DbPort: get("dbport"),
+			DbUser: get("dbuser"),
+			DbPass: get("dbpass"),
+		}
+
+		rows = append(rows, parseInventoryRecord(row, len(record), len(header), get))
+	}
+}
+
+// parseInventoryRecord sets the typed fields of a CSV row. Errors are kept in
+// the row, so that one bad row doesn't stop the import
+func parseInventoryRecord(row inventoryRow, fields, columns int, get func(string) string) inventoryRow {
+	var err error
+
+	if fields != columns {
+		row.err = fmt.Sprintf("Expected %d fields but got %d", columns, fields)
+		return row
+	}
+
+	if readonly := get("readonly"); readonly != "" {
+		if row.Readonly, err = strconv.ParseBool(readonly); err != nil {
+			row.err = fmt.Sprintf("Invalid readonly '%s'", readonly)
+			return row
+		}
+	}
+
+	ids := []struct {
+		column string
+		id     *int
+	}{{"storageid", &row.StorageID}, {"scheduleid", &row.ScheduleID}, {"retentionid", &row.RetentionID}}
+
+	for _, c := range ids {
+		if value := get(c.column); value != "" {
+			if *c.id, err = strconv.Atoi(value); err != nil {
+				row.err = fmt.Sprintf("Invalid %s '%s'", c.column, value)
+				return row
+			}
+		}
+	}
+
+	return row
+}
+
+// checkInventoryPermissions warns if an inventory with passwords can be read
+// by other users
+func checkInventoryPermissions(path string, rows []inventoryRow) {
+	for _, row := range rows {
+		if row.DbPass == "" {
+			continue
+		}
+
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0077 != 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: %s has database passwords and can be accessed by other users (mode %s)\n",
+				path, info.Mode().Perm())
+		}
+
+		return
+	}
+}
+
+// importServers creates the servers and jobs of the rows, up to concurrency
+// at a time. Results are in the same order as rows
+func importServers(client api.ClientAPI, rows []inventoryRow, defaults inventoryRow,
+	concurrency int) ([]importResult, error) {
+
+	servers, err := client.ListServers()
+
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := client.ListJobs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]api.Server{}
+
+	for _, s := range servers {
+		existing[s.Name] = s
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]importResult, len(rows))
+	seen := map[string]int{}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, row := range rows {
+		results[i] = importResult{Row: row.line, Name: row.Name}
+
+		if row.err == "" {
+			row.err = missingInventoryField(row)
+		}
+
+		if row.err != "" {
+			results[i].Error = row.err
+			continue
+		}
+
+		if line, ok := seen[row.Name]; ok {
+			results[i].Error = fmt.Sprintf("Duplicated name, already in row %d", line)
+			continue
+		}
+
+		seen[row.Name] = row.line
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, row inventoryRow) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			importServer(client, row, defaults, existing, jobs, &results[i])
+		}(i, row)
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
+
+// missingInventoryField returns the error of a row without one of the fields
+// every server needs, if any
+func missingInventoryField(row inventoryRow) string {
+	switch {
+	case row.Name == "":
+		return "Missing name"
+	case row.DbType == "":
+		return "Missing db_type"
+	case row.DbHost == "":
+		return "Missing db_host"
+	}
+
+	return ""
+}
+
+// importServer creates the server of a row unless it exists, and links it to
+// a job if the row has a storage, schedule and retention
+func importServer(client api.ClientAPI, row, defaults inventoryRow, existing map[string]api.Server,
+	jobs []api.BackupJob, result *importResult) {
+
+	if row.StorageID == 0 {
+		row.StorageID = defaults.StorageID
+	}
+
+	if row.ScheduleID == 0 {
+		row.ScheduleID = defaults.ScheduleID
+	}
+
+	if row.RetentionID == 0 {
+		row.RetentionID = defaults.RetentionID
+	}
+
+	server, ok := existing[row.Name]
+
+	if ok {
+		result.Server = "exists"
+	} else {
+		dbType, err := api.ParseDatabaseType(row.DbType)
+
+		if err != nil {
+			result.Error = err.Error()
+			return
+		}
+
+		server, err = client.CreateServer(row.Name, dbType, row.Readonly, row.DbHost, row.DbPort, row.DbUser, row.DbPass)
+
+		if err != nil {
+			result.Error = err.Error()
+			return
+		}
+
+		result.Server = "created"
+	}
+
+	result.ServerID = server.ID
+
+	link := row.StorageID > 0 || row.ScheduleID > 0 || row.RetentionID > 0
+
+	if !link {
+		return
+	}
+
+	if row.StorageID == 0 || row.ScheduleID == 0 || row.RetentionID == 0 {
+		result.Error = "Storage, schedule and retention IDs must all be set to link the server to a job"
+		return
+	}
+
+	for _, j := range jobs {
+		if j.ServerID == server.ID && j.StorageID == row.StorageID {
+			result.Job, result.JobID = "exists", j.ID
+			return
+		}
+	}
+
+	job, err := client.CreateJob(api.BackupJob{
+		Name:        row.Name,
+		ServerID:    server.ID,
+		StorageID:   row.StorageID,
+		ScheduleID:  row.ScheduleID,
+		RetentionID: row.RetentionID,
+		BackupType:  api.BACKUP_FULL,
+		Enabled:     true,
+	})
+
+	if err != nil {
+		result.Error = fmt.Sprintf("%s, while linking the server to a job", err)
+		return
+	}
+
+	result.Job, result.JobID = "created", job.ID
+}
+
+func printImportResults(out io.Writer, results []importResult) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROW\tNAME\tSERVER\tJOB\tERROR")
+
+	counts := map[string]int{}
+
+	for _, r := range results {
+		server, job := "-", "-"
+
+		if r.Server != "" {
+			server = fmt.Sprintf("%s (%d)", r.Server, r.ServerID)
+			counts[r.Server]++
+		}
+
+		if r.Job != "" {
+			job = fmt.Sprintf("%s (%d)", r.Job, r.JobID)
+		}
+
+		if r.Error != "" {
+			counts["failed"]++
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", r.Row, r.Name, server, job, r.Error)
+	}
+
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d rows: %d servers created, %d already existed, %d failed\n",
+		len(results), counts["created"], counts["exists"], counts["failed"])
+}
+
+func init() {
+	serverCmd.AddCommand(serverImport)
+
+	serverImport.Flags().String("file", "", "The CSV or JSON inventory file")
+	serverImport.MarkFlagRequired("file")
+	serverImport.Flags().String("format", "", "The inventory format (csv or json), guessed from the file extension if not set")
+	serverImport.Flags().Int("concurrency", 4, "How many servers to create at a time")
+	serverImport.Flags().Bool("json", false, "Output the result of each row in JSON format")
+	serverImport.Flags().Int("storage-id", 0, "Link the servers without storage to this storage ID")
+	serverImport.Flags().Int("schedule-id", 0, "Link the servers without schedule to this schedule ID")
+	serverImport.Flags().Int("retention-id", 0, "Link the servers without retention to this retention ID")
+}
@@ -0,0 +1,283 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/binlogicinc/cloudbackup-cli/signature"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// binlogicScriptPublicKey is the minisign public key Binlogic signs the server
+// scripts with. It must be the release signing key, so that builds from source
+// verify the scripts without any build flag
+const binlogicScriptPublicKey = ""
+
+// scriptPublicKey is the pinned key server scripts must be signed with. It can
+// be replaced at build time with -ldflags "-X github.com/binlogicinc/cloudbackup-cli/cmd.scriptPublicKey=..."
+// or with script-public-key in the config file, for ex: for self hosted
+// panels that sign scripts with their own key
+var scriptPublicKey = binlogicScriptPublicKey
+
+var heredocRegexp = regexp.MustCompile(`^<<-?\s*['"]?(\w+)['"]?`)
+
+// addScriptFlags adds the flags of the commands that run server scripts
+func addScriptFlags(cmd *cobra.Command, action string) {
+	cmd.Flags().Bool("dry-run", false, "Output "+action+" script instead of executing it")
+	cmd.Flags().Bool("inspect", false, "Show the verification result and the commands the "+action+" script will run, without executing it")
+	cmd.Flags().Bool("allow-unsigned", false, "Execute the "+action+" script even if it is not signed or there is no trusted key to verify it")
+}
+
+// trustedScriptKey returns the key scripts are verified with: the pinned key,
+// unless the config file sets script-public-key. The override is only read
+// from the config file, never from the environment or flags, and only if the
+// file is owned by root and no other user can write it, as --config and $HOME
+// can be chosen by whoever controls the environment of a root shell
+func trustedScriptKey() string {
+	path := viper.ConfigFileUsed()
+
+	if path == "" {
+		return scriptPublicKey
+	}
+
+	config := viper.New()
+	config.SetConfigFile(path)
+
+	if err := config.ReadInConfig(); err != nil {
+		return scriptPublicKey
+	}
+
+	key := strings.TrimSpace(config.GetString("script-public-key"))
+
+	if key == "" {
+		return scriptPublicKey
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return scriptPublicKey
+	}
+
+	if uid, ok := fileOwner(info); !ok || uid != 0 || info.Mode().Perm()&0022 != 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: ignoring script-public-key in %s, it is only trusted from a file "+
+			"owned by root that other users cannot write\n", path)
+
+		return scriptPublicKey
+	}
+
+	if scriptPublicKey != "" && key != scriptPublicKey {
+		fmt.Fprintf(os.Stderr, "WARNING: script-public-key in %s overrides the Binlogic key this build pins, "+
+			"scripts will be trusted if signed by that key instead\n", path)
+	}
+
+	return key
+}
+
+// verifyScript checks the script checksum and signature against the trusted
+// key, and reports if the signature was actually verified. Unsigned scripts
+// are only accepted if allowUnsigned is set, but scripts with a wrong
+// checksum or signature are always rejected
+func verifyScript(script api.Script, allowUnsigned bool) (verified bool, err error) {
+	if script.Checksum != "" {
+		if err := signature.VerifyChecksum(script.Body, script.Checksum); err != nil {
+			return false, fmt.Errorf("Script was tampered with or corrupted: %s", err)
+		}
+	}
+
+	key := trustedScriptKey()
+
+	if key == "" || len(script.Signature) == 0 {
+		if allowUnsigned {
+			return false, nil
+		}
+
+		if key == "" {
+			return false, fmt.Errorf("No trusted key to verify the script with, set script-public-key in the config file or pass --allow-unsigned")
+		}
+
+		return false, fmt.Errorf("Script is not signed, pass --allow-unsigned to execute it anyway")
+	}
+
+	pub, err := signature.ParsePublicKey(key)
+
+	if err != nil {
+		return false, fmt.Errorf("Invalid script-public-key: %s", err)
+	}
+
+	if err := signature.Verify(pub, script.Body, script.Signature); err != nil {
+		return false, fmt.Errorf("Script was tampered with or not signed by Binlogic: %s", err)
+	}
+
+	return true, nil
+}
+
+// runScript verifies the script and then, depending on the flags, shows its
+// commands, prints it or executes it with bash as root
+func runScript(cmd *cobra.Command, script api.Script) error {
+	verified, verifyErr := verifyScript(script, getBoolFlag(cmd, "allow-unsigned"))
+
+	if getBoolFlag(cmd, "inspect") {
+		switch {
+		case verifyErr != nil:
+			fmt.Println("Verification: FAILED,", verifyErr)
+		case verified:
+			fmt.Println("Verification: OK, signed by the trusted key")
+		case len(script.Signature) > 0:
+			fmt.Println("Verification: UNVERIFIED, there is no trusted key to check the signature, allowed by --allow-unsigned")
+		default:
+			fmt.Println("Verification: UNSIGNED, allowed by --allow-unsigned")
+		}
+
+		fmt.Println()
+
+		for i, c := range scriptCommands(script.Body) {
+			fmt.Printf("%3d  %s\n", i+1, c)
+		}
+
+		return nil
+	}
+
+	if getBoolFlag(cmd, "dry-run") {
+		if verifyErr != nil {
+			fmt.Fprintln(os.Stderr, "WARNING:", verifyErr)
+		}
+
+		fmt.Println(string(script.Body))
+
+		return nil
+	}
+
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	bash := exec.Command("bash")
+	bash.Stdout = os.Stdout
+	bash.Stderr = os.Stderr
+	bash.Stdin = bytes.NewReader(script.Body)
+
+	return bash.Run()
+}
+
+// scriptCommands returns the commands of a shell script, one per line,
+// skipping comments and joining continuation lines. Heredoc bodies are
+// summarized instead of listed as commands
+func scriptCommands(body []byte) []string {
+	var commands []string
+	var current string
+
+	lines := strings.Split(strings.Replace(string(body), "\r\n", "\n", -1), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if current == "" && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSpace(strings.TrimSuffix(line, "\\")) + " "
+			continue
+		}
+
+		current += line
+
+		if delimiter := heredocDelimiter(current); delimiter != "" {
+			n := 0
+
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != delimiter {
+				i++
+				n++
+			}
+
+			i++ // the heredoc terminator
+			current += fmt.Sprintf(" (%d lines of input)", n)
+		}
+
+		commands = append(commands, current)
+		current = ""
+	}
+
+	if current != "" {
+		commands = append(commands, strings.TrimSpace(current))
+	}
+
+	return commands
+}
+
+// heredocDelimiter returns the delimiter of the heredoc a command starts, if
+// any. Only << redirections count: not the shifts of arithmetic expressions,
+// here strings (<<<) nor anything quoted or commented out
+func heredocDelimiter(command string) string {
+	var quote byte
+	arithmetic := 0
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+
+		case c == '\\':
+			i++
+
+		case c == '\'' || c == '"':
+			quote = c
+
+		case c == '#' && (i == 0 || command[i-1] == ' ' || command[i-1] == '\t' || command[i-1] == ';'):
+			return ""
+
+		case strings.HasPrefix(command[i:], "(("):
+			arithmetic++
+			i++
+
+		case arithmetic > 0 && strings.HasPrefix(command[i:], "))"):
+			arithmetic--
+			i++
+
+		case arithmetic > 0:
+			// shifts and comparisons
+
+		case strings.HasPrefix(command[i:], "<<<"):
+			i += 2
+
+		case strings.HasPrefix(command[i:], "<<"):
+			if m := heredocRegexp.FindStringSubmatch(command[i:]); m != nil {
+				return m[1]
+			}
+
+			i++
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,76 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeredocDelimiter(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"cat <<EOF > /etc/agent.conf", "EOF"},
+		{"cat <<-EOF", "EOF"},
+		{"cat << 'END'", "END"},
+		{`cat <<"END" | sudo tee /etc/x`, "END"},
+		{"X=$((1<<4))", ""},
+		{"X=$(( 1 << 4 ))", ""},
+		{"(( mask = 1<<bit ))", ""},
+		{"grep foo <<< \"$x\"", ""},
+		{"grep foo <<<EOF", ""},
+		{`echo "a <<EOF b"`, ""},
+		{"echo 'a <<EOF b'", ""},
+		{`echo a \<<EOF`, ""},
+		{"echo hi # cat <<EOF", ""},
+		{"X=$((1<<4)); cat <<EOF", "EOF"},
+		{"echo $#; cat <<EOF", "EOF"},
+		{"rm -rf /tmp/x", ""},
+	}
+
+	for _, tt := range tests {
+		if got := heredocDelimiter(tt.command); got != tt.want {
+			t.Errorf("heredocDelimiter(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestScriptCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"comments and blank lines", "#!/bin/bash\n\n# install\napt-get update\n",
+			[]string{"apt-get update"}},
+		{"continuation lines", "curl -o /tmp/a \\\n  https://example.com/a\nbash /tmp/a\n",
+			[]string{"curl -o /tmp/a https://example.com/a", "bash /tmp/a"}},
+		{"heredoc", "cat <<EOF > /etc/a.conf\nkey=1\nother=2\nEOF\nsystemctl restart a\n",
+			[]string{"cat <<EOF > /etc/a.conf (2 lines of input)", "systemctl restart a"}},
+		{"arithmetic shift", "X=$((1<<4))\nrm -rf /tmp/x\ncurl https://example.com | sh\n",
+			[]string{"X=$((1<<4))", "rm -rf /tmp/x", "curl https://example.com | sh"}},
+		{"here string", "grep -q a <<< \"$x\"\nrm -rf /tmp/x\n",
+			[]string{"grep -q a <<< \"$x\"", "rm -rf /tmp/x"}},
+		{"quoted heredoc marker", "echo \"<<EOF\"\nrm -rf /tmp/x\n",
+			[]string{"echo \"<<EOF\"", "rm -rf /tmp/x"}},
+	}
+
+	for _, tt := range tests {
+		if got := scriptCommands([]byte(tt.script)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: scriptCommands() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/agent"
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+// healthCheckInterval is how often the server status is polled after an upgrade
+const healthCheckInterval = 5 * time.Second
+
+var serverUninstall = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Uninstall a server agent from this host or print the uninstall script via stdout",
+	Long: "Uninstall a server agent from this host or print the uninstall script via stdout.\n\n" +
+		"The script is verified like the install one, see 'server install --help'.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serverID := getIntFlag(cmd, "server-id")
+
+		if serverID == 0 {
+			return fmt.Errorf("Server ID cannot be zero")
+		}
+
+		script, err := getAPIClient().GetServerUninstall(serverID)
+
+		if err != nil {
+			return err
+		}
+
+		return runScript(cmd, script)
+	},
+}
+
+var serverUpgrade = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade a server agent in this host or print the upgrade script via stdout",
+	Long: "Upgrade a server agent in this host or print the upgrade script via stdout.\n\n" +
+		"The script is verified like the install one, see 'server install --help'. Before upgrading, " +
+		"the agent files (--agent-path) are archived in --backup-dir. If the upgrade fails or the agent " +
+		"does not report a heartbeat to the API within --health-timeout, the files are restored and the " +
+		"agent service restarted.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serverID := getIntFlag(cmd, "server-id")
+
+		if serverID == 0 {
+			return fmt.Errorf("Server ID cannot be zero")
+		}
+
+		client := getAPIClient()
+		script, err := client.GetServerUpgrade(serverID)
+
+		if err != nil {
+			return err
+		}
+
+		if getBoolFlag(cmd, "dry-run") || getBoolFlag(cmd, "inspect") {
+			return runScript(cmd, script)
+		}
+
+		if _, err := verifyScript(script, getBoolFlag(cmd, "allow-unsigned")); err != nil {
+			return err
+		}
+
+		if err := checkRoot(); err != nil {
+			return err
+		}
+
+		paths, _ := cmd.Flags().GetStringSlice("agent-path")
+		backupDir := getStringFlag(cmd, "backup-dir")
+		timeout, _ := cmd.Flags().GetDuration("health-timeout")
+
+		if err := os.MkdirAll(backupDir, 0700); err != nil {
+			return err
+		}
+
+		archive := filepath.Join(backupDir, fmt.Sprintf("cloudbackup-agent-%d-%s.tar.gz",
+			serverID, time.Now().Format("20060102-150405")))
+
+		if err := agent.Backup(archive, paths); err != nil {
+			return fmt.Errorf("%s, while backing up the agent, nothing was upgraded", err)
+		}
+
+		printVerbose("Agent backed up to %s", archive)
+
+		// the panel clock decides what a newer heartbeat is, not ours
+		before, err := client.GetServerStatus(serverID)
+
+		if err != nil {
+			return fmt.Errorf("%s, while checking the agent health, nothing was upgraded", err)
+		}
+
+		upgradeErr := runScript(cmd, script)
+
+		if upgradeErr == nil {
+			upgradeErr = waitForHeartbeat(client, serverID, before.LastHeartbeat.Time, timeout)
+		}
+
+		if upgradeErr == nil {
+			printVerbose("Agent upgraded successfully")
+			return nil
+		}
+
+		fmt.Fprintln(os.Stderr, "Upgrade failed, rolling back:", upgradeErr)
+
+		if err := agent.Restore(archive, paths); err != nil {
+			return fmt.Errorf("%s, while rolling back the upgrade, restore %s manually", err, archive)
+		}
+
+		if err := agent.Restart(getStringFlag(cmd, "agent-service")); err != nil {
+			return fmt.Errorf("%s, after rolling back the upgrade", err)
+		}
+
+		return fmt.Errorf("Upgrade failed and was rolled back: %s", upgradeErr)
+	},
+}
+
+// waitForHeartbeat polls the API until the server agent reports a heartbeat
+// after since, or the timeout expires. since must be a heartbeat time sent
+// by the API, as the local clock may be skewed from the panel one
+func waitForHeartbeat(client api.ClientAPI, serverID int, since time.Time, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	var status api.ServerStatus
+	var err error
+
+	for {
+		status, err = client.GetServerStatus(serverID)
+
+		if err == nil && status.HeartbeatSince(since) {
+			printVerbose("Agent %s is online", status.AgentVersion)
+			return nil
+		}
+
+		if time.Now().Add(healthCheckInterval).After(deadline) {
+			break
+		}
+
+		time.Sleep(healthCheckInterval)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s, while checking the agent health", err)
+	}
+
+	return fmt.Errorf("Agent did not report a heartbeat within %s of the upgrade", timeout)
+}
+
+func init() {
+	serverCmd.AddCommand(serverUninstall)
+	serverCmd.AddCommand(serverUpgrade)
+
+	serverUninstall.Flags().Int("server-id", 0, "Server ID")
+	serverUninstall.MarkFlagRequired("server-id")
+	addScriptFlags(serverUninstall, "uninstall")
+
+	serverUpgrade.Flags().Int("server-id", 0, "Server ID")
+	serverUpgrade.MarkFlagRequired("server-id")
+	addScriptFlags(serverUpgrade, "upgrade")
+
+	serverUpgrade.Flags().StringSlice("agent-path", []string{agent.DefaultConfigDir, agent.DefaultBinary},
+		"Agent files and directories to back up before the upgrade and restore if it fails")
+	serverUpgrade.Flags().String("agent-service", agent.DefaultService, "The agent systemd service to restart on rollback")
+	serverUpgrade.Flags().String("backup-dir", "/var/backups", "Where to keep the agent backup")
+	serverUpgrade.Flags().Duration("health-timeout", 2*time.Minute, "How long to wait for the upgraded agent heartbeat before rolling back")
+}
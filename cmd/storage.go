@@ -16,9 +16,8 @@ package cmd
 import (
 	"fmt"
 	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/binlogicinc/cloudbackup-cli/probe"
 	"github.com/spf13/cobra"
-	"os"
-	"strings"
 )
 
 var storageCmd = &cobra.Command{
@@ -27,116 +26,139 @@ var storageCmd = &cobra.Command{
 }
 
 var storageNew = &cobra.Command{
-	Use:     "new",
-	Short:   "Add new backup storage to Binlogic CloudBackup",
-	PreRunE: checkRequiredFlags,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		name := getStringFlag(cmd, "name")
-		path := getStringFlag(cmd, "path")
-		bucket := getStringFlag(cmd, "bucket")
-		accessKey := getStringFlag(cmd, "storage-access-key")
-		secretKey := getStringFlag(cmd, "storage-secret-key")
-		regionEndpoint := getStringFlag(cmd, "region-endpoint")
+	Use:   "new",
+	Short: "Add new backup storage to Binlogic CloudBackup",
+	Long: "Add new backup storage to Binlogic CloudBackup.\n\n" +
+		"Each storage type has its own subcommand and flags, for ex: " +
+		"'storage new s3 --bucket ...' or 'storage new local --path ...'",
+}
 
-		stType := getStringFlag(cmd, "storage-type")
+var storageUpdate = &cobra.Command{
+	Use:   "update",
+	Short: "Updates a backup storage in Binlogic CloudBackup",
+	Long: "Updates a backup storage in Binlogic CloudBackup.\n\n" +
+		"Each storage type has its own subcommand and flags, for ex: " +
+		"'storage update s3 --storage-id 1 --bucket ...'. Only the flags passed are updated",
+}
 
-		storageType, err := api.ParseStorageType(stType)
+// newStorageTypeCmd creates the 'storage new <type>' subcommand for the given
+// storage type, with a flag for every field of its config
+func newStorageTypeCmd(storageType api.StorageType) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     storageType.Name(),
+		Short:   "Add new " + storageType.String() + " backup storage to Binlogic CloudBackup",
+		PreRunE: checkRequiredFlags,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := api.NewStorageConfig(storageType)
 
-		if err != nil {
-			return err
-		}
+			if err != nil {
+				return err
+			}
 
-		if err := validateStorageParams(storageType, path, bucket, accessKey,
-			secretKey, regionEndpoint); err != nil {
+			if err := setStorageConfigFlags(cmd, config, false); err != nil {
+				return err
+			}
 
-			return err
-		}
+			storage := api.NewStorage(getStringFlag(cmd, "name"), config)
 
-		storage, err := getAPIClient().CreateStorage(name, storageType, path, bucket,
-			regionEndpoint, accessKey, secretKey)
+			if err := storage.Validate(); err != nil {
+				return err
+			}
 
-		if err != nil {
-			return err
-		}
+			if getBoolFlag(cmd, "verify") {
+				if err := testStorage(storage); err != nil {
+					return err
+				}
+			}
 
-		printVerbose("Storage created successfully")
+			storage, err = getAPIClient().CreateStorage(storage)
 
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(storage.JSONString())
-		} else {
-			fmt.Println(storage)
-		}
+			if err != nil {
+				return err
+			}
 
-		return nil
-	},
-}
+			printVerbose("Storage created successfully")
+			printResource(cmd, storage, true)
 
-var storageUpdate = &cobra.Command{
-	Use:     "update",
-	Short:   "Updates a backup storage in Binlogic CloudBackup",
-	PreRunE: checkRequiredFlags,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		storageID := getIntFlag(cmd, "storage-id")
+			return nil
+		},
+	}
 
-		if storageID == 0 {
-			return fmt.Errorf("Storage ID cannot be zero")
-		}
+	config, _ := api.NewStorageConfig(storageType)
 
-		storage, err := getAPIClient().GetStorage(storageID)
+	cmd.Flags().Bool("json", false, "Output info in JSON format")
+	cmd.Flags().Bool("verify", false, "Test the storage connectivity and permissions before creating it")
 
-		if err != nil {
-			return err
-		}
+	cmd.Flags().String("name", "", "The storage name to show in the control panel")
+	cmd.MarkFlagRequired("name")
 
-		if name := getStringFlag(cmd, "name"); name != "" {
-			storage.Name = name
-		}
+	addStorageConfigFlags(cmd, config)
+
+	return cmd
+}
+
+// updateStorageTypeCmd creates the 'storage update <type>' subcommand for the
+// given storage type. Only the flags passed are changed in the storage
+func updateStorageTypeCmd(storageType api.StorageType) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     storageType.Name(),
+		Short:   "Updates a " + storageType.String() + " backup storage in Binlogic CloudBackup",
+		PreRunE: checkRequiredFlags,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			storageID := getIntFlag(cmd, "storage-id")
+
+			if storageID == 0 {
+				return fmt.Errorf("Storage ID cannot be zero")
+			}
 
-		// if the user passed the storage type
-		if stType := getStringFlag(cmd, "storage-type"); stType != "" {
-			storageType, err := api.ParseStorageType(stType)
+			storage, err := getAPIClient().GetStorage(storageID)
 
 			if err != nil {
 				return err
 			}
 
-			if storageType != storage.StorageType {
+			if storage.StorageType != storageType || storage.Config == nil {
 				return fmt.Errorf("Cant change storage type from %s to %s", storage.StorageType, storageType)
 			}
-		}
 
-		if storage.StorageType == api.STORAGE_LOCAL && getStringFlag(cmd, "path") != "" {
-			storage.LocalPath = getStringFlag(cmd, "path")
-		} else {
-			if bucket := getStringFlag(cmd, "bucket"); bucket != "" {
-				storage.Bucket = bucket
+			before := snapshot(storage)
+
+			if name := getStringFlag(cmd, "name"); name != "" {
+				storage.Name = name
 			}
 
-			if accessKey := getStringFlag(cmd, "storage-access-key"); accessKey != "" {
-				storage.AccessKey = accessKey
+			if err := setStorageConfigFlags(cmd, storage.Config, true); err != nil {
+				return err
 			}
 
-			if secretKey := getStringFlag(cmd, "storage-secret-key"); secretKey != "" {
-				storage.SecretKey = secretKey
+			if unchanged(before, storage) {
+				printVerbose("Storage is up to date")
+				printResource(cmd, storage, false)
+
+				return nil
 			}
 
-			if regionEndpoint := getStringFlag(cmd, "region-endpoint"); regionEndpoint != "" {
-				storage.RegionEndpoint = regionEndpoint
+			if err := getAPIClient().UpdateStorage(storage); err != nil {
+				return err
 			}
-		}
 
-		if err := getAPIClient().UpdateStorage(storage); err != nil {
-			return err
-		}
+			printResource(cmd, storage, true)
 
-		if getBoolFlag(cmd, "json") {
-			fmt.Println(storage.JSONString())
-		} else {
-			fmt.Println(storage)
-		}
+			return nil
+		},
+	}
 
-		return nil
-	},
+	config, _ := api.NewStorageConfig(storageType)
+
+	cmd.Flags().Bool("json", false, "Output info in JSON format")
+	cmd.Flags().String("name", "", "The storage name to show in the control panel")
+
+	cmd.Flags().Int("storage-id", 0, "Storage ID")
+	cmd.MarkFlagRequired("storage-id")
+
+	addStorageConfigFlags(cmd, config)
+
+	return cmd
 }
 
 var storageDelete = &cobra.Command{
@@ -150,12 +172,12 @@ var storageDelete = &cobra.Command{
 			return fmt.Errorf("Storage ID cannot be zero")
 		}
 
-		if err := getAPIClient().DeleteStorage(storageID); err != nil {
-			fmt.Fprint(os.Stderr, err, "\n")
-		} else {
-			printVerbose("Storage deleted successfully")
+		if err := recordDelete(storageID, getAPIClient().DeleteStorage(storageID)); err != nil {
+			return err
 		}
 
+		printVerbose("Storage deleted successfully")
+
 		return nil
 	},
 }
@@ -172,25 +194,47 @@ var storageInfo = &cobra.Command{
 		}
 
 		if storage, err := getAPIClient().GetStorage(storageID); err != nil {
-			fmt.Fprint(os.Stderr, err, "\n")
+			return err
 		} else {
-			if getBoolFlag(cmd, "json") {
-				fmt.Println(storage.JSONString())
-			} else {
-				fmt.Println(storage)
-			}
+			printResource(cmd, storage, false)
 		}
 
 		return nil
 	},
 }
 
+var storageTest = &cobra.Command{
+	Use:   "test",
+	Short: "Test connectivity and permissions of a backup storage in Binlogic CloudBackup",
+	Long: "Test connectivity and permissions of a backup storage in Binlogic CloudBackup.\n\n" +
+		"Cloud storages are tested with a write/read/list/delete round trip of a probe object " +
+		"using the storage credentials. Local storages are tested in this host, checking the path " +
+		"permissions and free space.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageID := getIntFlag(cmd, "storage-id")
+
+		if storageID == 0 {
+			return fmt.Errorf("Storage ID cannot be zero")
+		}
+
+		storage, err := getAPIClient().GetStorage(storageID)
+
+		if err != nil {
+			return err
+		}
+
+		return testStorage(storage)
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(storageCmd)
 	storageCmd.AddCommand(storageNew)
 	storageCmd.AddCommand(storageUpdate)
 	storageCmd.AddCommand(storageDelete)
 	storageCmd.AddCommand(storageInfo)
+	storageCmd.AddCommand(storageTest)
 
 	// Here you will define your flags and configuration settings.
 
@@ -205,64 +249,31 @@ func init() {
 	storageDelete.Flags().Int("storage-id", 0, "Storage ID")
 	storageDelete.MarkFlagRequired("storage-id")
 
-	addCreateStorageFlags(storageNew)
-	addCreateStorageFlags(storageUpdate)
+	storageTest.Flags().Int("storage-id", 0, "Storage ID")
+	storageTest.MarkFlagRequired("storage-id")
 
-	storageUpdate.Flags().Int("storage-id", 0, "Storage ID")
-	storageUpdate.MarkFlagRequired("storage-id")
+	for _, storageType := range api.StorageTypes() {
+		storageNew.AddCommand(newStorageTypeCmd(storageType))
+		storageUpdate.AddCommand(updateStorageTypeCmd(storageType))
+	}
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// serverCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func validateStorageParams(st api.StorageType, path, bucket, accessKey, secretKey,
-	regionEndpoint string) error {
-
-	switch st {
-	case api.STORAGE_LOCAL:
-		if strings.TrimSpace(path) == "" {
-			return fmt.Errorf("Local path cannot be empty")
-		}
+func testStorage(s api.Storage) error {
+	fmt.Printf("Testing %s storage %q\n", s.StorageType, s.Name)
 
-	case api.STORAGE_S3, api.STORAGE_GOOGLE, api.STORAGE_DIGITALOCEAN, api.STORAGE_ALIBABA:
-		if strings.TrimSpace(bucket) == "" {
-			return fmt.Errorf("Storage bucket cannot be empty")
-		}
+	report := probe.Storage(s)
 
-		if strings.TrimSpace(accessKey) == "" {
-			return fmt.Errorf("Storage access key cannot be empty")
-		}
-
-		if strings.TrimSpace(secretKey) == "" {
-			return fmt.Errorf("Storage secret key cannot be empty")
-		}
+	fmt.Println(report)
 
-		if strings.TrimSpace(regionEndpoint) == "" {
-			return fmt.Errorf("Storage region endpoint cannot be empty")
-		}
+	if !report.Ok() {
+		return fmt.Errorf("Storage test failed")
 	}
 
-	return nil
-}
+	printVerbose("Storage test passed")
 
-func addCreateStorageFlags(cmd *cobra.Command) {
-	cmd.Flags().Bool("json", false, "Output info in JSON format")
-
-	cmd.Flags().String("name", "", "The storage name to show in the control panel")
-	cmd.MarkFlagRequired("name")
-
-	cmd.Flags().String("storage-type", "", "The storage type: "+
-		"'local', 's3', 'google', 'digitalocean' or 'alibaba' ")
-
-	cmd.MarkFlagRequired("storage-type")
-
-	cmd.Flags().String("path", "", "The local storage full path to store the backups (for ex: '/data/backups')")
-
-	cmd.Flags().String("bucket", "", "The cloud bucket to store the backups into (does not apply to local storage)")
-	cmd.Flags().String("storage-access-key", "", "The access key for the cloud storage (does not apply to local storage)")
-	cmd.Flags().String("storage-secret-key", "", "The secret key for the cloud storage (does not apply to local storage)")
-
-	cmd.Flags().String("region-endpoint", "", "The cloud storage region endpoint, without https, as reported by your "+
-		"provider (for ex: 's3.ap-south-1.amazonaws.com')")
+	return nil
 }
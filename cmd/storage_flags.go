@@ -0,0 +1,97 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/spf13/cobra"
+)
+
+// storageConfigFields calls fn for every field of the config tagged with
+// `flag`, walking into embedded structs
+func storageConfigFields(v reflect.Value, fn func(field reflect.Value, name, usage string, secret bool)) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			storageConfigFields(v.Field(i), fn)
+			continue
+		}
+
+		if name := f.Tag.Get("flag"); name != "" {
+			fn(v.Field(i), name, f.Tag.Get("usage"), f.Tag.Get("secret") == "true")
+		}
+	}
+}
+
+// addStorageConfigFlags adds a flag for every tagged field of the config,
+// using the config current values as defaults
+func addStorageConfigFlags(cmd *cobra.Command, config api.StorageConfig) {
+	storageConfigFields(reflect.ValueOf(config).Elem(), func(field reflect.Value, name, usage string, secret bool) {
+		if secret {
+			addSecretFlag(cmd, name, field.String(), usage)
+			return
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			cmd.Flags().String(name, field.String(), usage)
+		case reflect.Int:
+			cmd.Flags().Int(name, int(field.Int()), usage)
+		case reflect.Bool:
+			cmd.Flags().Bool(name, field.Bool(), usage)
+		}
+	})
+}
+
+// setStorageConfigFlags copies the flag values into the config. When
+// onlyChanged is true, flags not passed in the command line are skipped
+func setStorageConfigFlags(cmd *cobra.Command, config api.StorageConfig, onlyChanged bool) error {
+	var err error
+
+	storageConfigFields(reflect.ValueOf(config).Elem(), func(field reflect.Value, name, usage string, secret bool) {
+		if secret {
+			if err != nil || (onlyChanged && !secretFlagChanged(cmd, name)) {
+				return
+			}
+
+			var value string
+
+			if value, err = getSecretFlag(cmd, name); err == nil {
+				field.SetString(value)
+			}
+
+			return
+		}
+
+		if onlyChanged && !cmd.Flags().Changed(name) {
+			return
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(getStringFlag(cmd, name))
+		case reflect.Int:
+			field.SetInt(int64(getIntFlag(cmd, name)))
+		case reflect.Bool:
+			field.SetBool(getBoolFlag(cmd, name))
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,511 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+	"github.com/binlogicinc/cloudbackup-cli/probe"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const gigabyte = 1 << 30
+
+// defaultStoragePrices are the list prices in USD per GB-month for the
+// standard storage class of each provider. Self hosted storages are free
+var defaultStoragePrices = map[string]float64{
+	"s3":           0.023,
+	"google":       0.020,
+	"digitalocean": 0.020,
+	"alibaba":      0.017,
+	"azure":        0.018,
+	"b2":           0.006,
+	"wasabi":       0.007,
+}
+
+var storageUsage = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the space used and monthly cost of backup storages in Binlogic CloudBackup",
+	Long: "Report the space used and monthly cost of backup storages in Binlogic CloudBackup.\n\n" +
+		"Backup sizes are aggregated per storage, server and month from the backup catalog. " +
+		"Prices are in USD per GB-month, and can be overridden with --price or a [storage-prices] " +
+		"table in the config file, keyed by storage type (for ex: s3 = 0.0125).\n\n" +
+		"The projection estimates the size each server will settle at under its current " +
+		"retention policy, based on the average size and frequency of its recent backups. " +
+		"Failed backups are left out of both.\n\n" +
+		"With --csv only the usage per month is written, or the projection with --projection.",
+	PreRunE: checkRequiredFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prices, err := storagePrices(cmd)
+
+		if err != nil {
+			return err
+		}
+
+		client := getAPIClient()
+		storageID := getIntFlag(cmd, "storage-id")
+
+		var storages []api.Storage
+
+		if storageID != 0 {
+			storage, err := client.GetStorage(storageID)
+
+			if err != nil {
+				return err
+			}
+
+			storages = []api.Storage{storage}
+		} else if storages, err = client.ListStorages(); err != nil {
+			return err
+		}
+
+		servers, err := client.ListServers()
+
+		if err != nil {
+			return err
+		}
+
+		serverNames := map[int]string{}
+
+		for _, s := range servers {
+			serverNames[s.ID] = s.Name
+		}
+
+		backups, err := client.ListBackups(api.BackupFilter{StorageID: storageID})
+
+		if err != nil {
+			return err
+		}
+
+		report := newUsageReport(storages, serverNames, backups, prices)
+
+		if err := report.project(client.GetRetention, time.Now()); err != nil {
+			return err
+		}
+
+		if getBoolFlag(cmd, "csv") {
+			if getBoolFlag(cmd, "projection") {
+				return report.writeProjectionCSV(os.Stdout)
+			}
+
+			return report.writeCSV(os.Stdout)
+		}
+
+		report.writeTable(os.Stdout)
+
+		return nil
+	},
+}
+
+// storagePrices merges the default price table with the config file table and
+// the --price flags, in that order of precedence
+func storagePrices(cmd *cobra.Command) (map[string]float64, error) {
+	prices := map[string]float64{}
+
+	for k, v := range defaultStoragePrices {
+		prices[k] = v
+	}
+
+	for k, v := range viper.GetStringMap("storage-prices") {
+		storageType, err := api.ParseStorageType(k)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s, while reading [storage-prices] in config file", err)
+		}
+
+		price, err := cast.ToFloat64E(v)
+
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("Invalid price '%v' for %s in config file", v, k)
+		}
+
+		prices[storageType.Name()] = price
+	}
+
+	priceFlags, _ := cmd.Flags().GetStringSlice("price")
+
+	for _, p := range priceFlags {
+		parts := strings.SplitN(p, "=", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid price '%s', expected storage-type=USD (for ex: s3=0.023)", p)
+		}
+
+		storageType, err := api.ParseStorageType(parts[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		price, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("Invalid price '%s' for %s", parts[1], parts[0])
+		}
+
+		prices[storageType.Name()] = price
+	}
+
+	return prices, nil
+}
+
+type usageKey struct {
+	storageID int
+	serverID  int
+}
+
+type usageRow struct {
+	storage string
+	server  string
+	month   string
+	backups int
+	size    int64
+	cost    float64
+}
+
+type usageProjection struct {
+	storage       string
+	server        string
+	retention     string
+	current       int64
+	projected     int64
+	projectedCost float64
+	known         bool
+}
+
+type usageReport struct {
+	storages    map[int]api.Storage
+	serverNames map[int]string
+	prices      map[string]float64
+	groups      map[usageKey][]api.Backup
+	rows        []usageRow
+	projections []usageProjection
+}
+
+func newUsageReport(storages []api.Storage, serverNames map[int]string, backups []api.Backup,
+	prices map[string]float64) *usageReport {
+
+	r := &usageReport{
+		storages:    map[int]api.Storage{},
+		serverNames: serverNames,
+		prices:      prices,
+		groups:      map[usageKey][]api.Backup{},
+	}
+
+	for _, s := range storages {
+		r.storages[s.ID] = s
+	}
+
+	type monthKey struct {
+		usageKey
+		month string
+	}
+
+	months := map[monthKey]*usageRow{}
+
+	for _, b := range backups {
+		if _, ok := r.storages[b.StorageID]; !ok || !b.Succeeded() {
+			continue
+		}
+
+		key := usageKey{b.StorageID, b.ServerID}
+		r.groups[key] = append(r.groups[key], b)
+
+		mk := monthKey{key, b.StartedAt.Format("2006-01")}
+		row, ok := months[mk]
+
+		if !ok {
+			row = &usageRow{storage: r.storageName(b.StorageID), server: r.serverName(b.ServerID), month: mk.month}
+			months[mk] = row
+		}
+
+		row.backups++
+		row.size += b.Size
+		row.cost = r.cost(b.StorageID, row.size)
+	}
+
+	for _, row := range months {
+		r.rows = append(r.rows, *row)
+	}
+
+	sort.Slice(r.rows, func(i, j int) bool {
+		a, b := r.rows[i], r.rows[j]
+
+		if a.storage != b.storage {
+			return a.storage < b.storage
+		}
+
+		if a.server != b.server {
+			return a.server < b.server
+		}
+
+		return a.month < b.month
+	})
+
+	return r
+}
+
+func (r *usageReport) storageName(id int) string {
+	return fmt.Sprintf("%s (%d)", r.storages[id].Name, id)
+}
+
+func (r *usageReport) serverName(id int) string {
+	if name, ok := r.serverNames[id]; ok {
+		return fmt.Sprintf("%s (%d)", name, id)
+	}
+
+	return fmt.Sprintf("%d", id)
+}
+
+// cost is the monthly cost in USD of keeping size bytes in the storage
+func (r *usageReport) cost(storageID int, size int64) float64 {
+	price := r.prices[r.storages[storageID].StorageType.Name()]
+
+	return float64(size) / gigabyte * price
+}
+
+// project estimates the size every storage and server pair will settle at
+// under the retention policy of its latest backup
+func (r *usageReport) project(getRetention func(int) (api.Retention, error), now time.Time) error {
+	retentions := map[int]api.Retention{}
+
+	for key, backups := range r.groups {
+		sort.Slice(backups, func(i, j int) bool { return backups[i].StartedAt.Before(backups[j].StartedAt.Time) })
+
+		p := usageProjection{storage: r.storageName(key.storageID), server: r.serverName(key.serverID)}
+
+		for _, b := range backups {
+			p.current += b.Size
+		}
+
+		latest := backups[len(backups)-1]
+
+		if latest.RetentionID > 0 {
+			retention, ok := retentions[latest.RetentionID]
+
+			if !ok {
+				var err error
+
+				if retention, err = getRetention(latest.RetentionID); err != nil {
+					return err
+				}
+
+				retentions[latest.RetentionID] = retention
+			}
+
+			p.retention = retention.Name
+
+			if count, ok := retainedBackups(retention, backups, now); ok {
+				p.projected = int64(count * float64(averageSize(backups, 10)))
+				p.projectedCost = r.cost(key.storageID, p.projected)
+				p.known = true
+			}
+		}
+
+		r.projections = append(r.projections, p)
+	}
+
+	sort.Slice(r.projections, func(i, j int) bool {
+		a, b := r.projections[i], r.projections[j]
+
+		if a.storage != b.storage {
+			return a.storage < b.storage
+		}
+
+		return a.server < b.server
+	})
+
+	return nil
+}
+
+// averageSize is the mean size of the last n backups
+func averageSize(backups []api.Backup, n int) int64 {
+	if len(backups) < n {
+		n = len(backups)
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	var total int64
+
+	for _, b := range backups[len(backups)-n:] {
+		total += b.Size
+	}
+
+	return total / int64(n)
+}
+
+// backupsPerDay is the backup frequency over the last 30 days, or since the
+// first backup if there is less history than that
+func backupsPerDay(backups []api.Backup, now time.Time) float64 {
+	since := now.AddDate(0, 0, -30)
+
+	if first := backups[0].StartedAt.Time; first.After(since) {
+		since = first
+	}
+
+	days := now.Sub(since).Hours() / 24
+
+	if days < 1 {
+		days = 1
+	}
+
+	count := 0
+
+	for _, b := range backups {
+		if !b.StartedAt.Before(since) {
+			count++
+		}
+	}
+
+	return float64(count) / days
+}
+
+// retainedBackups is how many backups the retention keeps once it is full.
+// It returns false if it cannot be estimated for the retention type
+func retainedBackups(r api.Retention, backups []api.Backup, now time.Time) (float64, bool) {
+	switch r.RetentionType {
+	case api.RETENTION_BY_COUNT:
+		return float64(r.Count), true
+
+	case api.RETENTION_BY_DAYS:
+		return backupsPerDay(backups, now) * float64(r.Count), true
+
+	case api.RETENTION_GFS:
+		// upper bound, as the same backup may fill a daily and a weekly slot. A
+		// slot is only filled if a backup ran in it, for ex: with weekly backups
+		// just one in seven daily slots has a backup
+		perDay := backupsPerDay(backups, now)
+		count := 0.0
+
+		for _, slot := range []struct {
+			keep int
+			days float64
+		}{{r.KeepDaily, 1}, {r.KeepWeekly, 7}, {r.KeepMonthly, 30}, {r.KeepYearly, 365}} {
+			count += float64(slot.keep) * math.Min(1, perDay*slot.days)
+		}
+
+		return count, true
+	}
+
+	return 0, false
+}
+
+func formatSize(size int64) string {
+	if size < 0 {
+		return "-" + probe.FormatBytes(uint64(-size))
+	}
+
+	return probe.FormatBytes(uint64(size))
+}
+
+func (r *usageReport) writeTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "STORAGE\tSERVER\tMONTH\tBACKUPS\tSIZE\tCOST/MONTH")
+
+	var totalSize int64
+	var totalCost float64
+
+	for _, row := range r.rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t$%.2f\n", row.storage, row.server, row.month,
+			row.backups, formatSize(row.size), row.cost)
+
+		totalSize += row.size
+		totalCost += row.cost
+	}
+
+	fmt.Fprintf(w, "TOTAL\t\t\t\t%s\t$%.2f\n", formatSize(totalSize), totalCost)
+	w.Flush()
+
+	fmt.Fprintln(out)
+
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STORAGE\tSERVER\tRETENTION\tCURRENT\tPROJECTED\tGROWTH\tPROJECTED COST/MONTH")
+
+	for _, p := range r.projections {
+		if !p.known {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t-\t-\t-\n", p.storage, p.server, p.retention, formatSize(p.current))
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t$%.2f\n", p.storage, p.server, p.retention,
+			formatSize(p.current), formatSize(p.projected), formatSize(p.projected-p.current), p.projectedCost)
+	}
+
+	w.Flush()
+}
+
+func (r *usageReport) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+
+	w.Write([]string{"storage", "server", "month", "backups", "size_bytes", "cost_usd"})
+
+	for _, row := range r.rows {
+		w.Write([]string{row.storage, row.server, row.month, strconv.Itoa(row.backups),
+			strconv.FormatInt(row.size, 10), strconv.FormatFloat(row.cost, 'f', 2, 64)})
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+func (r *usageReport) writeProjectionCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+
+	w.Write([]string{"storage", "server", "retention", "current_bytes", "projected_bytes",
+		"growth_bytes", "projected_cost_usd"})
+
+	for _, p := range r.projections {
+		projected, growth, cost := "", "", ""
+
+		if p.known {
+			projected = strconv.FormatInt(p.projected, 10)
+			growth = strconv.FormatInt(p.projected-p.current, 10)
+			cost = strconv.FormatFloat(p.projectedCost, 'f', 2, 64)
+		}
+
+		w.Write([]string{p.storage, p.server, p.retention, strconv.FormatInt(p.current, 10),
+			projected, growth, cost})
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+func init() {
+	storageCmd.AddCommand(storageUsage)
+
+	storageUsage.Flags().Int("storage-id", 0, "Only report this storage ID")
+	storageUsage.Flags().Bool("csv", false, "Output in CSV format")
+	storageUsage.Flags().Bool("projection", false, "With --csv, output the projection instead of the usage per month")
+	storageUsage.Flags().StringSlice("price", nil, "Price in USD per GB-month for a storage type, "+
+		"overriding the defaults (for ex: --price s3=0.0125 --price wasabi=0.0059)")
+}
@@ -0,0 +1,330 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package discover finds the database instances running or configured in
+// this host, to propose them as CloudBackup servers
+package discover
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+)
+
+// Instance is a database instance found in this host
+type Instance struct {
+	// Product is the database flavour, for ex: MariaDB for a MySQL DbType
+	Product string `json:"product"`
+
+	// Server is the proposed server definition, without name or credentials
+	Server api.Server `json:"server"`
+
+	// Sources are where the instance was found, for ex: 'tcp 0.0.0.0:3306 (mysqld)'
+	Sources []string `json:"sources"`
+}
+
+func (i Instance) String() string {
+	return fmt.Sprintf("%s on %s:%s, found in %s", i.Product, i.Server.DbHost, i.Server.DbPort,
+		strings.Join(i.Sources, ", "))
+}
+
+// ProposedName is a server name for the instance, unique in this host
+func (i Instance) ProposedName(hostname string) string {
+	return fmt.Sprintf("%s-%s-%s", hostname, strings.ToLower(strings.Replace(i.Product, " ", "-", -1)), i.Server.DbPort)
+}
+
+// The database flavours that can be discovered
+const (
+	ProductMySQL    = "MySQL"
+	ProductMariaDB  = "MariaDB"
+	ProductPercona  = "Percona Server"
+	ProductMongoDB  = "MongoDB"
+	ProductPostgres = "PostgreSQL"
+)
+
+// dbTypeNames are the --db-type names of each product, as parsed by
+// api.ParseDatabaseType
+var dbTypeNames = map[string]string{
+	ProductMySQL:    "mysql",
+	ProductMariaDB:  "mariadb",
+	ProductPercona:  "percona_server",
+	ProductMongoDB:  "mongodb",
+	ProductPostgres: "postgresql",
+}
+
+// DbTypeName is the database type name of the instance product, for ex: to
+// pass to api.ParseDatabaseType or as --db-type
+func (i Instance) DbTypeName() string {
+	return dbTypeNames[i.Product]
+}
+
+var defaultPorts = map[string]int{
+	ProductMySQL:    3306,
+	ProductMariaDB:  3306,
+	ProductPercona:  3306,
+	ProductMongoDB:  27017,
+	ProductPostgres: 5432,
+}
+
+// finding is a single clue of an instance, merged by product family and port
+type finding struct {
+	product string
+	host    string
+	port    int
+	source  string
+}
+
+// Discover scans the listening sockets and well known config files of this
+// host. Instances found in several places are merged by database type and port
+func Discover() ([]Instance, error) {
+	findings, err := listeners()
+
+	if err != nil {
+		return nil, err
+	}
+
+	findings = append(findings, configFiles()...)
+
+	return merge(findings), nil
+}
+
+func merge(findings []finding) []Instance {
+	type key struct {
+		dbType string
+		port   int
+	}
+
+	byKey := map[key]*Instance{}
+	var keys []key
+
+	for _, f := range findings {
+		dbType, err := api.ParseDatabaseType(dbTypeNames[f.product])
+
+		if err != nil {
+			continue
+		}
+
+		server := api.Server{DbType: dbType, DbHost: f.host, DbPort: strconv.Itoa(f.port)}
+
+		k := key{server.DbType.String(), f.port}
+		instance, ok := byKey[k]
+
+		if !ok {
+			instance = &Instance{Product: f.product, Server: server}
+			byKey[k] = instance
+			keys = append(keys, k)
+		}
+
+		// a running process tells the flavour better than a config file
+		if instance.Product == ProductMySQL && f.product != ProductMySQL {
+			instance.Product = f.product
+		}
+
+		// unix socket and specific addresses are more precise than localhost
+		if instance.Server.DbHost == "localhost" && f.host != "localhost" {
+			instance.Server.DbHost = f.host
+		}
+
+		instance.Sources = append(instance.Sources, f.source)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].dbType != keys[j].dbType {
+			return keys[i].dbType < keys[j].dbType
+		}
+
+		return keys[i].port < keys[j].port
+	})
+
+	instances := make([]Instance, len(keys))
+
+	for i, k := range keys {
+		instances[i] = *byKey[k]
+	}
+
+	return instances
+}
+
+// connectHost is the host to connect to a database bound to addr
+func connectHost(addr string) string {
+	addr = strings.Trim(strings.TrimSpace(strings.Split(addr, ",")[0]), `"'`)
+
+	switch addr {
+	case "", "*", "0.0.0.0", "::", "[::]", "127.0.0.1", "::1", "localhost":
+		return "localhost"
+	}
+
+	return addr
+}
+
+var mysqlConfigs = []string{
+	"/etc/my.cnf", "/etc/mysql/my.cnf", "/etc/my.cnf.d/*.cnf", "/etc/mysql/conf.d/*.cnf",
+	"/etc/mysql/mysql.conf.d/*.cnf", "/etc/mysql/mariadb.conf.d/*.cnf",
+}
+
+var mongoConfigs = []string{"/etc/mongod.conf", "/etc/mongodb.conf"}
+
+var postgresConfigs = []string{
+	"/etc/postgresql/*/*/postgresql.conf", "/var/lib/pgsql/data/postgresql.conf",
+	"/var/lib/pgsql/*/data/postgresql.conf", "/var/lib/postgresql/data/postgresql.conf",
+}
+
+// configFiles looks for database server configs in their default locations
+func configFiles() []finding {
+	var findings []finding
+
+	for _, path := range globAll(mysqlConfigs) {
+		if f, ok := parseMySQLConfig(path); ok {
+			findings = append(findings, f)
+		}
+	}
+
+	for _, path := range globAll(mongoConfigs) {
+		if f, ok := parseKeyValueConfig(path, ProductMongoDB, "port", "bindip", "bind_ip"); ok {
+			findings = append(findings, f)
+		}
+	}
+
+	for _, path := range globAll(postgresConfigs) {
+		if f, ok := parseKeyValueConfig(path, ProductPostgres, "port", "listen_addresses"); ok {
+			findings = append(findings, f)
+		}
+	}
+
+	return findings
+}
+
+func globAll(patterns []string) []string {
+	var paths []string
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		paths = append(paths, matches...)
+	}
+
+	return paths
+}
+
+// parseMySQLConfig reads the port and bind address of the server sections of
+// a my.cnf file. Files without server sections, for ex: client configs, are
+// skipped
+func parseMySQLConfig(path string) (finding, bool) {
+	f := finding{product: ProductMySQL, host: "localhost", source: "config " + path}
+	server := false
+	inServerSection := false
+
+	err := scanConfig(path, func(line string) {
+		if strings.HasPrefix(line, "[") {
+			section := strings.ToLower(strings.Trim(line, "[] "))
+			inServerSection = section == "mysqld" || section == "server" || section == "mariadb" ||
+				strings.HasPrefix(section, "mysqld-") || strings.HasPrefix(section, "mariadb-")
+			server = server || inServerSection
+
+			if strings.HasPrefix(section, "mariadb") {
+				f.product = ProductMariaDB
+			}
+
+			return
+		}
+
+		if !inServerSection {
+			return
+		}
+
+		key, value := splitKeyValue(line, "=")
+
+		switch key {
+		case "port":
+			f.port, _ = strconv.Atoi(value)
+		case "bind-address", "bind_address":
+			f.host = connectHost(value)
+		}
+	})
+
+	if err != nil || !server {
+		return f, false
+	}
+
+	if f.port == 0 {
+		f.port = defaultPorts[ProductMySQL]
+	}
+
+	return f, true
+}
+
+// parseKeyValueConfig reads the port and bind address of a 'key = value' or
+// YAML 'key: value' config file
+func parseKeyValueConfig(path, product, portKey string, hostKeys ...string) (finding, bool) {
+	f := finding{product: product, host: "localhost", port: defaultPorts[product], source: "config " + path}
+
+	err := scanConfig(path, func(line string) {
+		key, value := splitKeyValue(line, "=:")
+
+		if key == portKey {
+			if port, err := strconv.Atoi(value); err == nil {
+				f.port = port
+			}
+		}
+
+		for _, k := range hostKeys {
+			if key == k {
+				f.host = connectHost(value)
+			}
+		}
+	})
+
+	return f, err == nil
+}
+
+// scanConfig calls fn with every non empty, non comment line of the file
+func scanConfig(path string, fn func(line string)) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+
+		if line = strings.TrimSpace(line); line != "" {
+			fn(line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func splitKeyValue(line, separators string) (string, string) {
+	i := strings.IndexAny(line, separators)
+
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(line[:i])), strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+}
@@ -0,0 +1,316 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package discover
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tcpListen      = "0A"
+	unixAcceptConn = 0x10000
+)
+
+// products of the database server processes, by their command name
+var processProducts = map[string]string{
+	"mysqld":   ProductMySQL,
+	"mariadbd": ProductMariaDB,
+	"mongod":   ProductMongoDB,
+	"postgres": ProductPostgres,
+}
+
+var (
+	mongoSocketRegexp    = regexp.MustCompile(`^mongodb-(\d+)\.sock$`)
+	postgresSocketRegexp = regexp.MustCompile(`^\.s\.PGSQL\.(\d+)$`)
+)
+
+// listeners finds the database servers listening in TCP ports or unix sockets
+// of this host, using /proc
+func listeners() ([]finding, error) {
+	processes := socketProcesses()
+	var findings []finding
+
+	// the TCP port of each database process, as MySQL socket names have none
+	tcpPorts := map[string]int{}
+
+	for _, file := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		err := scanProcNet(file, func(fields []string) {
+			if len(fields) < 10 || fields[3] != tcpListen {
+				return
+			}
+
+			ip, port, err := parseProcAddr(fields[1])
+			process, ok := processes[fields[9]]
+
+			if err != nil || !ok {
+				return
+			}
+
+			if product, ok := processProducts[process.name]; ok {
+				addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+
+				tcpPorts[process.pid] = port
+				findings = append(findings, finding{
+					product: process.product(product, "tcp", addr),
+					host:    connectHost(ip.String()),
+					port:    port,
+					source:  fmt.Sprintf("tcp %s (%s)", addr, process.name),
+				})
+			}
+		})
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	err := scanProcNet("/proc/net/unix", func(fields []string) {
+		if len(fields) < 8 {
+			return
+		}
+
+		if flags, err := strconv.ParseUint(fields[3], 16, 32); err != nil || flags&unixAcceptConn == 0 {
+			return
+		}
+
+		if f, ok := socketFinding(fields[7]); ok {
+			if process, ok := processes[fields[6]]; ok {
+				f.product = process.product(f.product, "unix", fields[7])
+
+				if port, ok := tcpPorts[process.pid]; ok && f.product != ProductPostgres {
+					f.port = port
+				}
+			}
+
+			findings = append(findings, f)
+		}
+	})
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+// socketFinding recognizes the default unix socket names of each database
+func socketFinding(path string) (finding, bool) {
+	name := filepath.Base(path)
+	f := finding{host: "localhost", source: "unix " + path}
+
+	switch {
+	case name == "mysqld.sock" || name == "mysql.sock":
+		f.product, f.port = ProductMySQL, defaultPorts[ProductMySQL]
+
+	case mongoSocketRegexp.MatchString(name):
+		f.product = ProductMongoDB
+		f.port, _ = strconv.Atoi(mongoSocketRegexp.FindStringSubmatch(name)[1])
+
+	case postgresSocketRegexp.MatchString(name):
+		// libpq takes the socket directory as host
+		f.product, f.host = ProductPostgres, filepath.Dir(path)
+		f.port, _ = strconv.Atoi(postgresSocketRegexp.FindStringSubmatch(name)[1])
+
+	default:
+		return f, false
+	}
+
+	return f, true
+}
+
+type process struct {
+	pid  string
+	name string
+	exe  string
+}
+
+var (
+	productsMu sync.Mutex
+	products   = map[string]string{}
+)
+
+// product tells MariaDB and Percona Server apart from MySQL, as all of them
+// may run as mysqld. The binaries found are never executed: the flavour is
+// read from the greeting of the running server at network addr, or from the
+// package that installed the binary. The result is cached per process
+func (p process) product(product, network, addr string) string {
+	if product != ProductMySQL {
+		return product
+	}
+
+	productsMu.Lock()
+	defer productsMu.Unlock()
+
+	if cached, ok := products[p.pid]; ok {
+		return cached
+	}
+
+	flavour := strings.ToLower(mysqlGreetingVersion(network, addr) + " " + debianPackage(p.exe))
+
+	switch {
+	case strings.Contains(flavour, "mariadb"):
+		product = ProductMariaDB
+	case strings.Contains(flavour, "percona"):
+		product = ProductPercona
+	}
+
+	products[p.pid] = product
+
+	return product
+}
+
+// mysqlGreetingVersion returns the server version sent by MySQL in the
+// greeting packet when a client connects, like 5.5.5-10.6.12-MariaDB
+func mysqlGreetingVersion(network, addr string) string {
+	conn, err := net.DialTimeout(network, addr, 2*time.Second)
+
+	if err != nil {
+		return ""
+	}
+
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// 3 bytes length, 1 byte sequence, 1 byte protocol version 10, then the
+	// null terminated version
+	packet := make([]byte, 128)
+	n, _ := conn.Read(packet)
+
+	if n < 6 || packet[4] != 10 {
+		return ""
+	}
+
+	version := packet[5:n]
+
+	if end := strings.IndexByte(string(version), 0); end >= 0 {
+		version = version[:end]
+	}
+
+	return string(version)
+}
+
+// debianPackage returns the name of the dpkg package that installed the
+// file, empty if not known
+func debianPackage(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	lists, _ := filepath.Glob("/var/lib/dpkg/info/*.list")
+
+	for _, list := range lists {
+		content, err := ioutil.ReadFile(list)
+
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			if line == path {
+				return strings.TrimSuffix(filepath.Base(list), ".list")
+			}
+		}
+	}
+
+	return ""
+}
+
+// socketProcesses maps the socket inodes open by every process to the
+// process. Processes of other users are only visible when running as root
+func socketProcesses() map[string]process {
+	processes := map[string]process{}
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+
+		pidDir := filepath.Dir(filepath.Dir(fd))
+		comm, err := ioutil.ReadFile(filepath.Join(pidDir, "comm"))
+
+		if err != nil {
+			continue
+		}
+
+		exe, _ := os.Readlink(filepath.Join(pidDir, "exe"))
+		inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+		processes[inode] = process{filepath.Base(pidDir), strings.TrimSpace(string(comm)), exe}
+	}
+
+	return processes
+}
+
+func scanProcNet(file string, fn func(fields []string)) error {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+
+	for scanner.Scan() {
+		fn(strings.Fields(scanner.Text()))
+	}
+
+	return scanner.Err()
+}
+
+// parseProcAddr parses a /proc/net/tcp address like 0100007F:0CEA, where the
+// IP is in host byte order in 32 bit words
+func parseProcAddr(s string) (net.IP, int, error) {
+	parts := strings.Split(s, ":")
+
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("Malformed address %s", s)
+	}
+
+	raw, err := hex.DecodeString(parts[0])
+
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("Malformed address %s", s)
+	}
+
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("Malformed port in address %s", s)
+	}
+
+	return net.IP(raw), int(port), nil
+}
@@ -0,0 +1,117 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// MinFreeBytes is the free space under which a local storage is reported as failing
+const MinFreeBytes = 1 << 30
+
+// Local checks that path exists in this host, is a directory the current
+// user can list and write into, and that it has enough free space
+func Local(path string) Report {
+	var r Report
+
+	if !r.run("Check path", func() (string, error) {
+		if !filepath.IsAbs(path) {
+			return "", fmt.Errorf("Path %s is not absolute", path)
+		}
+
+		info, err := os.Stat(path)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !info.IsDir() {
+			return "", fmt.Errorf("Path %s is not a directory", path)
+		}
+
+		return fmt.Sprintf("%s %s", path, info.Mode()), nil
+	}) {
+		return r
+	}
+
+	r.run("List directory", func() (string, error) {
+		entries, err := ioutil.ReadDir(path)
+
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%d entries", len(entries)), nil
+	})
+
+	r.run("Write and delete probe file", func() (string, error) {
+		f, err := ioutil.TempFile(path, ".cloudbackup-probe-")
+
+		if err != nil {
+			return "", err
+		}
+
+		name := f.Name()
+		_, err = f.WriteString("Binlogic CloudBackup storage probe")
+
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		if rerr := os.Remove(name); err == nil {
+			err = rerr
+		}
+
+		return name, err
+	})
+
+	r.run("Check free space", func() (string, error) {
+		free, err := freeSpace(path)
+
+		if err != nil {
+			return "", err
+		}
+
+		detail := fmt.Sprintf("%s available", FormatBytes(free))
+
+		if free < MinFreeBytes {
+			return detail, fmt.Errorf("Less than %s available", FormatBytes(MinFreeBytes))
+		}
+
+		return detail, nil
+	})
+
+	return r
+}
+
+// FormatBytes prints a byte count using binary units, for ex: '1.5 GiB'
+func FormatBytes(b uint64) string {
+	const unit = 1024
+
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := uint64(unit), 0
+
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
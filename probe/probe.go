@@ -0,0 +1,221 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/binlogicinc/cloudbackup-cli/api"
+)
+
+// Step is the outcome of a single check run against a storage. Skipped steps
+// could not run in this host and don't make the report fail
+type Step struct {
+	Name     string        `json:"name"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Skipped  bool          `json:"skipped,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// skip is returned by a step that can not run in this host, with the reason
+type skip string
+
+func (s skip) Error() string {
+	return string(s)
+}
+
+func (s Step) Ok() bool {
+	return s.Error == ""
+}
+
+func (s Step) String() string {
+	status := " OK "
+
+	if !s.Ok() {
+		status = "FAIL"
+	} else if s.Skipped {
+		status = "SKIP"
+	}
+
+	line := fmt.Sprintf("[%s] %s (%s)", status, s.Name, s.Duration-s.Duration%time.Millisecond)
+
+	if s.Detail != "" {
+		line += ": " + s.Detail
+	}
+
+	if !s.Ok() {
+		line += ": " + s.Error
+	}
+
+	return line
+}
+
+// Report holds every step run against a storage, in execution order
+type Report struct {
+	Steps []Step `json:"steps"`
+}
+
+func (r Report) Ok() bool {
+	for _, s := range r.Steps {
+		if !s.Ok() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r Report) String() string {
+	lines := make([]string, 0, len(r.Steps))
+
+	for _, s := range r.Steps {
+		lines = append(lines, s.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// run executes fn as a named step and appends its result to the report.
+// It returns false if the step failed so callers can stop early
+func (r *Report) run(name string, fn func() (string, error)) bool {
+	start := time.Now()
+	detail, err := fn()
+
+	step := Step{Name: name, Detail: detail, Duration: time.Since(start)}
+
+	if reason, ok := err.(skip); ok {
+		step.Skipped, step.Detail = true, string(reason)
+		err = nil
+	}
+
+	if err != nil {
+		step.Error = err.Error()
+	}
+
+	r.Steps = append(r.Steps, step)
+
+	return err == nil
+}
+
+// Storage checks that the given storage is reachable and writable with its
+// configured credentials. Cloud storages get a write/read/list/delete round
+// trip of a probe object, local and mounted storages get their path checked
+// on this host. Azure and SFTP storages are reported as skipped
+func Storage(s api.Storage) Report {
+	switch c := s.Config.(type) {
+	case *api.LocalConfig:
+		return Local(c.Path)
+
+	case *api.NFSConfig:
+		return Local(c.MountPoint)
+
+	case *api.SMBConfig:
+		return Local(c.MountPoint)
+
+	case *api.S3Config:
+		client := NewS3Client(c.RegionEndpoint, c.Bucket, c.AccessKey, c.SecretKey)
+		client.Prefix = c.ObjectPrefix
+		client.Header = objectHeader(c.Type(), c.CloudOptions)
+
+		return S3(client)
+
+	case *api.S3CompatibleConfig:
+		client := NewS3Client(c.RegionEndpoint, c.Bucket, c.AccessKey, c.SecretKey)
+		client.PathStyle = c.PathStyle
+		client.Prefix = c.ObjectPrefix
+		client.Header = objectHeader(c.Type(), c.CloudOptions)
+
+		return S3(client)
+
+	case *api.AzureConfig, *api.SFTPConfig:
+		var r Report
+
+		r.run("Connect to storage", func() (string, error) {
+			return "", skip(fmt.Sprintf("Testing %s storages is not supported yet", s.StorageType))
+		})
+
+		return r
+	}
+
+	var r Report
+
+	r.run("Detect storage type", func() (string, error) {
+		return "", fmt.Errorf("Storage type %s cannot be tested", s.StorageType)
+	})
+
+	return r
+}
+
+// objectHeader returns the headers backups are uploaded with for the cloud
+// options, so that bucket policies requiring them (for ex: encryption) are
+// checked by the probe too. Object lock is left out, as it would prevent
+// deleting the probe object
+func objectHeader(t api.StorageType, o api.CloudOptions) http.Header {
+	header := http.Header{}
+
+	// storages created before encryption was normalized may have any case
+	o.Encryption = strings.ToLower(strings.TrimSpace(o.Encryption))
+
+	switch t {
+	case api.STORAGE_GOOGLE:
+		// sse-s3 is the default google managed encryption
+		if o.Encryption == api.ENCRYPTION_SSE_KMS {
+			header.Set("x-goog-encryption-kms-key-name", o.KMSKeyID)
+		}
+
+		if o.StorageClass != "" {
+			header.Set("x-goog-storage-class", o.StorageClass)
+		}
+
+	case api.STORAGE_ALIBABA:
+		switch o.Encryption {
+		case api.ENCRYPTION_SSE_S3:
+			header.Set("x-oss-server-side-encryption", "AES256")
+
+		case api.ENCRYPTION_SSE_KMS:
+			header.Set("x-oss-server-side-encryption", "KMS")
+
+			if o.KMSKeyID != "" {
+				header.Set("x-oss-server-side-encryption-key-id", o.KMSKeyID)
+			}
+		}
+
+		if o.StorageClass != "" {
+			header.Set("x-oss-storage-class", o.StorageClass)
+		}
+
+	default:
+		switch o.Encryption {
+		case api.ENCRYPTION_SSE_S3:
+			header.Set("x-amz-server-side-encryption", "AES256")
+
+		case api.ENCRYPTION_SSE_KMS:
+			header.Set("x-amz-server-side-encryption", "aws:kms")
+
+			if o.KMSKeyID != "" {
+				header.Set("x-amz-server-side-encryption-aws-kms-key-id", o.KMSKeyID)
+			}
+		}
+
+		if o.StorageClass != "" {
+			header.Set("x-amz-storage-class", o.StorageClass)
+		}
+	}
+
+	return header
+}
@@ -0,0 +1,422 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat  = "20060102T150405Z"
+	amzShortFormat = "20060102"
+	defaultRegion  = "us-east-1"
+)
+
+// S3Client is a minimal client for S3 compatible object storages (AWS S3,
+// Google Cloud Storage interoperability, DigitalOcean Spaces and Alibaba OSS)
+// signing requests with AWS signature version 4
+type S3Client struct {
+	Scheme    string
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+
+	// Prefix is prepended to the probe object key, for storages restricted
+	// to a folder inside the bucket
+	Prefix string
+
+	// Header is sent, and signed, with the probe object upload, for ex: the
+	// server side encryption and storage class of the backups
+	Header http.Header
+
+	httpClient http.Client
+}
+
+// NewS3Client creates a client for the given endpoint, which defaults to https
+// unless it starts with 'http://' (for ex: a local MinIO)
+func NewS3Client(endpoint, bucket, accessKey, secretKey string) *S3Client {
+	scheme := "https"
+	endpoint = strings.TrimSpace(endpoint)
+
+	if strings.HasPrefix(endpoint, "http://") {
+		scheme = "http"
+	}
+
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	return &S3Client{
+		Scheme:     scheme,
+		Endpoint:   endpoint,
+		Region:     RegionFromEndpoint(endpoint),
+		Bucket:     bucket,
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		httpClient: http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// RegionFromEndpoint guesses the signing region from the endpoint host as
+// reported by the providers, for ex: 's3.ap-south-1.amazonaws.com',
+// 'nyc3.digitaloceanspaces.com' or 'oss-cn-hangzhou.aliyuncs.com'
+func RegionFromEndpoint(endpoint string) string {
+	host := strings.ToLower(endpoint)
+
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	parts := strings.Split(host, ".")
+
+	switch {
+	case strings.HasSuffix(host, ".amazonaws.com"):
+		for _, p := range parts[:len(parts)-2] { // skip amazonaws.com
+			if p == "s3" || p == "dualstack" || p == "s3-external-1" {
+				continue
+			}
+
+			if strings.HasPrefix(p, "s3-") {
+				return strings.TrimPrefix(p, "s3-")
+			}
+
+			return p
+		}
+
+	case strings.HasSuffix(host, ".wasabisys.com"), strings.HasSuffix(host, ".backblazeb2.com"):
+		// s3.eu-central-1.wasabisys.com, s3.us-west-002.backblazeb2.com
+		if len(parts) > 3 {
+			return parts[1]
+		}
+
+	case strings.HasSuffix(host, ".digitaloceanspaces.com"):
+		return parts[0]
+
+	case strings.HasSuffix(host, ".aliyuncs.com"):
+		return strings.TrimSuffix(parts[0], "-internal")
+
+	case host == "storage.googleapis.com":
+		return "auto"
+	}
+
+	return defaultRegion
+}
+
+func (c *S3Client) Put(key string, data []byte) error {
+	resp, err := c.do("PUT", key, nil, c.Header, data)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return checkS3Response(resp)
+}
+
+func (c *S3Client) Get(key string) ([]byte, error) {
+	resp, err := c.do("GET", key, nil, nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if err := checkS3Response(resp); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (c *S3Client) Delete(key string) error {
+	resp, err := c.do("DELETE", key, nil, nil, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return checkS3Response(resp)
+}
+
+// List returns the keys in the bucket starting with prefix
+func (c *S3Client) List(prefix string) ([]string, error) {
+	resp, err := c.do("GET", "", url.Values{"prefix": {prefix}}, nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if err := checkS3Response(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Contents []struct {
+			Key string `xml:"Key"`
+		} `xml:"Contents"`
+	}
+
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s, while decoding list response", err)
+	}
+
+	keys := make([]string, 0, len(result.Contents))
+
+	for _, c := range result.Contents {
+		keys = append(keys, c.Key)
+	}
+
+	return keys, nil
+}
+
+func (c *S3Client) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	host := c.Endpoint
+	path := "/" + uriEncode(key, false)
+
+	if c.PathStyle {
+		path = "/" + uriEncode(c.Bucket, true) + path
+	} else {
+		host = c.Bucket + "." + host
+	}
+
+	rawQuery := canonicalQuery(query)
+	u := c.Scheme + "://" + host + path
+
+	if rawQuery != "" {
+		u += "?" + rawQuery
+	}
+
+	var reader io.Reader
+
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	c.sign(req, host, path, rawQuery, body, time.Now().UTC())
+
+	return c.httpClient.Do(req)
+}
+
+func (c *S3Client) sign(req *http.Request, host, path, rawQuery string, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format(amzDateFormat)
+	shortDate := now.Format(amzShortFormat)
+
+	req.Host = host
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	// every header is signed, as providers reject unsigned x-amz-* ones
+	names := []string{"host"}
+	values := map[string]string{"host": host}
+
+	for name, v := range req.Header {
+		name = strings.ToLower(name)
+		names = append(names, name)
+		values[name] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	sort.Strings(names)
+
+	signedHeaders := strings.Join(names, ";")
+	canonicalHeaders := ""
+
+	for _, name := range names {
+		canonicalHeaders += name + ":" + values[name] + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, rawQuery, canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := shortDate + "/" + c.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), shortDate)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func checkS3Response(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("Storage responded HTTP %d: %s %s", resp.StatusCode, s3Err.Code, s3Err.Message)
+	}
+
+	return fmt.Errorf("Storage responded HTTP %d: %s", resp.StatusCode, string(body))
+}
+
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var parts []string
+
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// uriEncode encodes s as required by the AWS signature, which differs from
+// url.QueryEscape in how spaces and '~' are handled
+func uriEncode(s string, encodeSlash bool) string {
+	var buff bytes.Buffer
+
+	for _, b := range []byte(s) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buff.WriteByte(b)
+
+		case b == '/' && !encodeSlash:
+			buff.WriteByte(b)
+
+		default:
+			fmt.Fprintf(&buff, "%%%02X", b)
+		}
+	}
+
+	return buff.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// S3 runs a write/read/list/delete round trip of a probe object
+func S3(c *S3Client) Report {
+	var r Report
+
+	key := fmt.Sprintf("cloudbackup-probe-%d.tmp", time.Now().UnixNano())
+
+	if c.Prefix != "" {
+		key = strings.TrimSuffix(c.Prefix, "/") + "/" + key
+	}
+
+	payload := []byte("Binlogic CloudBackup storage probe " + key)
+
+	if !r.run("Write probe object", func() (string, error) {
+		return fmt.Sprintf("%s/%s at %s (region %s)", c.Bucket, key, c.Endpoint, c.Region),
+			c.Put(key, payload)
+	}) {
+		return r
+	}
+
+	r.run("Read probe object", func() (string, error) {
+		data, err := c.Get(key)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !bytes.Equal(data, payload) {
+			return "", fmt.Errorf("Probe object content does not match what was written")
+		}
+
+		return fmt.Sprintf("%d bytes", len(data)), nil
+	})
+
+	r.run("List probe object", func() (string, error) {
+		keys, err := c.List(key)
+
+		if err != nil {
+			return "", err
+		}
+
+		for _, k := range keys {
+			if k == key {
+				return "", nil
+			}
+		}
+
+		return "", fmt.Errorf("Probe object not found in bucket listing")
+	})
+
+	// always try to clean up, even if reading or listing failed
+	r.run("Delete probe object", func() (string, error) {
+		return "", c.Delete(key)
+	})
+
+	return r
+}
